@@ -17,6 +17,6 @@ def login(
     user = auth_service.authenticate_user(user_login)
     if not user:
         raise HTTPException(status_code=401, detail="Неверное имя пользователя или пароль")
-    access_token = auth_service.create_access_token(data={"sub": user.username})
+    access_token = auth_service.create_access_token(data={"sub": user.username, "user_id": user.id})
     response.set_cookie(key="access_token", value=f"Bearer {access_token}", httponly=True)
     return {"message": "Успешный вход"}
@@ -14,7 +14,7 @@ def register(
 ):
     user = auth_service.register_user(user_create)
     access_token = (auth_service
-                    .create_access_token(data={"sub": user.username}))
+                    .create_access_token(data={"sub": user.username, "user_id": user.id}))
     response.set_cookie(
         key="access_token", value=f"Bearer {access_token}", httponly=True
     )
@@ -1,38 +1,29 @@
-from fastapi import Depends, HTTPException, Request, status
+from fastapi import Header, HTTPException, status
+from typing import Optional
+import hmac
 import os
-import requests
 from dotenv import load_dotenv
 
 load_dotenv()
-AUTH_SERVICE_URL = os.getenv("AUTH_SERVICE_URL", "http://auth:8002/")
 
-def get_token_from_cookie(request: Request):
-    token = request.cookies.get("access_token")
-    if not token:
+# Gateway проверяет токен и передаёт пользователя в заголовках X-User-Id и
+# X-Username вместе с общим секретом. Без секрета заголовкам не доверяем.
+GATEWAY_UPSTREAM_SECRET = os.getenv("GATEWAY_UPSTREAM_SECRET")
+if not GATEWAY_UPSTREAM_SECRET:
+    raise RuntimeError("GATEWAY_UPSTREAM_SECRET не задан")
+
+def get_current_user(
+    x_user_id: Optional[int] = Header(None),
+    x_username: Optional[str] = Header(None),
+    x_gateway_secret: Optional[str] = Header(None),
+):
+    from_gateway = x_gateway_secret is not None and hmac.compare_digest(
+        x_gateway_secret.encode(), GATEWAY_UPSTREAM_SECRET.encode()
+    )
+    if not from_gateway or x_user_id is None:
         raise HTTPException(
             status_code=status.HTTP_401_UNAUTHORIZED,
-            detail="Токен не найден",
+            detail="Не удалось проверить учетные данные",
             headers={"WWW-Authenticate": "Bearer"},
         )
-    if token.startswith("Bearer "):
-        token = token[7:]
-    return token
-
-def get_current_user(token: str = Depends(get_token_from_cookie)):
-    cookie = {"access_token": f"{token}"}
-    try:
-        response = requests.get(f"http://auth:8002/auth/info", cookies=cookie)
-        print(response.json())
-        if response.status_code != 200:
-            raise HTTPException(
-                status_code=status.HTTP_401_UNAUTHORIZED,
-                detail="Не удалось проверить учетные данные",
-                headers={"WWW-Authenticate": "Bearer"},
-            )
-        user_data = response.json()
-        return user_data  # Возвращаем данные пользователя
-    except requests.exceptions.ConnectionError:
-        raise HTTPException(
-            status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
-            detail="Сервис аутентификации недоступен",
-        )
\ No newline at end of file
+    return {"id": x_user_id, "username": x_username}  # Возвращаем данные пользователя
@@ -26,6 +26,11 @@ app.add_middleware(
     allow_headers=["*"],
 )
 
+# Проверка работоспособности сервиса
+@app.get("/healthcheck")
+async def healthcheck() -> str:
+    return "ok"
+
 # Эндпоинт для получения всех товаров в корзине текущего пользователя
 @app.get("/cart", response_model=List[schemas.CartItem])
 def get_cart_items(
@@ -1,5 +1,6 @@
 import axios from 'axios';
 import { useAuthStore } from '../store/useAuthStore'
+import { Product, ProductPage } from '../types';
 
 const API_URL = import.meta.env.VITE_API_URL || 'http://localhost';
 
@@ -55,4 +56,33 @@ export const getAuthToken = () => {
   const cookies = document.cookie.split(';');
   const tokenCookie = cookies.find(cookie => cookie.trim().startsWith('access_token='));
   return tokenCookie ? tokenCookie.split('=')[1] : null;
-};
\ No newline at end of file
+};
+
+// Страница каталога, cursor берется из next_cursor предыдущей страницы
+export const fetchProductPage = async (cursor?: string, limit = 20): Promise<ProductPage> => {
+  const response = await api.get<ProductPage>('/product/list', {
+    params: cursor ? { cursor, limit } : { limit },
+  });
+  return response.data;
+};
+
+// Продукты по ID, удаленные продукты пропускаются
+export const fetchProductsById = async (ids: number[]): Promise<Map<number, Product>> => {
+  const unique = Array.from(new Set(ids));
+  const results = await Promise.all(
+    unique.map(async (id) => {
+      try {
+        const response = await api.get(`/product/info/${id}`);
+        return response.data.product as Product;
+      } catch (err: any) {
+        if (err.response?.status === 404) return null;
+        throw err;
+      }
+    })
+  );
+  const products = new Map<number, Product>();
+  for (const product of results) {
+    if (product) products.set(product.id, product);
+  }
+  return products;
+};
@@ -3,7 +3,7 @@ import { Link, useNavigate } from 'react-router-dom';
 import { Minus, Plus, Trash2, ArrowLeft } from 'lucide-react';
 import { useCartStore } from '../store/useCartStore';
 import { useAuthStore } from '../store/useAuthStore';
-import { api } from '../api/client';
+import { api, fetchProductsById } from '../api/client';
 
 interface CartProduct {
   id: number;
@@ -41,13 +41,12 @@ export function CartPage() {
         const cartItems = cartResponse.data || [];
 
         // Fetch product details for each cart item
-        const productsResponse = await api.get('/product/list');
-        const products = productsResponse.data || [];
+        const products = await fetchProductsById(cartItems.map((item: CartProduct) => item.product_id));
 
         // Combine cart items with product details
         const cartWithProducts = cartItems.map((cartItem: CartProduct) => ({
           ...cartItem,
-          product: products.find((p: any) => p.id === cartItem.product_id)
+          product: products.get(cartItem.product_id)
         }));
 
         setCartProducts(cartWithProducts);
@@ -74,11 +73,10 @@ export function CartPage() {
       // Refresh cart data
       const response = await api.get('/cart');
       const cartItems = response.data || [];
-      const productsResponse = await api.get('/product/list');
-      const products = productsResponse.data || [];
+      const products = await fetchProductsById(cartItems.map((item: CartProduct) => item.product_id));
       const cartWithProducts = cartItems.map((cartItem: CartProduct) => ({
         ...cartItem,
-        product: products.find((p: any) => p.id === cartItem.product_id)
+        product: products.get(cartItem.product_id)
       }));
       setCartProducts(cartWithProducts);
     } catch (error) {
@@ -1,18 +1,21 @@
 import { useEffect, useState } from 'react';
 import { Product } from '../types';
 import { ProductCard } from '../components/ProductCard';
-import { api } from '../api/client';
+import { fetchProductPage } from '../api/client';
 
 export function HomePage() {
   const [products, setProducts] = useState<Product[]>([]);
   const [isLoading, setIsLoading] = useState(true);
   const [error, setError] = useState<string | null>(null);
+  const [nextCursor, setNextCursor] = useState<string | undefined>();
+  const [isLoadingMore, setIsLoadingMore] = useState(false);
 
   useEffect(() => {
     const fetchProducts = async () => {
       try {
-        const response = await api.get('/product/list');
-        setProducts(response.data);
+        const page = await fetchProductPage();
+        setProducts(page.items);
+        setNextCursor(page.next_cursor);
       } catch (err) {
         setError('Продукты не загружены..');
         console.error('Ошибка загрузки продуктов:', err);
@@ -24,6 +27,20 @@ export function HomePage() {
     fetchProducts();
   }, []);
 
+  const loadMore = async () => {
+    if (!nextCursor) return;
+    setIsLoadingMore(true);
+    try {
+      const page = await fetchProductPage(nextCursor);
+      setProducts((prev) => [...prev, ...page.items]);
+      setNextCursor(page.next_cursor);
+    } catch (err) {
+      console.error('Ошибка загрузки продуктов:', err);
+    } finally {
+      setIsLoadingMore(false);
+    }
+  };
+
   if (isLoading) {
     return (
       <div className="flex justify-center items-center min-h-[400px]">
@@ -48,6 +65,17 @@ export function HomePage() {
           <ProductCard key={product.id} product={product} />
         ))}
       </div>
+      {nextCursor && (
+        <div className="flex justify-center">
+          <button
+            onClick={loadMore}
+            disabled={isLoadingMore}
+            className="bg-amber-600 text-white px-6 py-2 rounded-lg hover:bg-amber-700 transition-colors disabled:opacity-50"
+          >
+            {isLoadingMore ? 'Загрузка...' : 'Показать ещё'}
+          </button>
+        </div>
+      )}
     </div>
   );
 }
\ No newline at end of file
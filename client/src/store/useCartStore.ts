@@ -1,5 +1,5 @@
 import { create } from 'zustand'
-import { api } from '../api/client'
+import { api, fetchProductsById } from '../api/client'
 import { CartItem } from '../types'
 import { useAuthStore } from './useAuthStore'
 
@@ -28,12 +28,11 @@ export const useCartStore = create<CartState>((set) => ({
       const cartResponse = await api.get('/cart')
       const cartItems: CartItem[] = cartResponse.data || []
 
-      const productsResponse = await api.get('/product/list')
-      const products = productsResponse.data || []
+      const products = await fetchProductsById(cartItems.map((ci: any) => ci.product_id))
 
-      const cartWithProducts = cartItems.map((ci) => ({
+      const cartWithProducts = cartItems.map((ci: any) => ({
         ...ci,
-        product: products.find((p: any) => p.id === ci.product_id) || null
+        product: products.get(ci.product_id) || null
       }))
 
       set({ items: cartWithProducts, isLoading: false })
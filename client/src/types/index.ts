@@ -9,6 +9,13 @@ export interface Product {
   photo: string;
 }
 
+export interface ProductPage {
+  items: Product[];
+  next_cursor?: string;
+  prev_cursor?: string;
+  total: number | null;
+}
+
 export interface User {
   username: string;
   email: string;
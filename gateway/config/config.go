@@ -1,24 +1,620 @@
+// Package config loads the gateway configuration.
+//
+// Settings are resolved in the following order, later sources override
+// earlier ones:
+//
+//  1. built-in defaults (see Default)
+//  2. the YAML file given by the -config flag or the GATEWAY_CONFIG variable
+//  3. environment variables, including the legacy names GATEWAY_PORT,
+//     AuthServiceUrl, ProductServiceUrl and Cart_service_url
+//  4. command line flags
+//
+// The result is validated before it is returned, see Config.Validate.
 package config
 
 import (
-	_ "log"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
+// Config is the complete configuration of the gateway
 type Config struct {
-	Gateway_port        string
-	Auth_service_url    string
-	Product_service_url string
-	Cart_service_url    string
+	Server Server `yaml:"server"`
+	// Upstreams are the backend services by name
+	Upstreams map[string]*Upstream `yaml:"upstreams"`
+	// Routes expose additional upstream endpoints without custom handlers
+	Routes []Route `yaml:"routes"`
+	Auth   Auth    `yaml:"auth"`
+	// PolicyFile is the role based access policy, DefaultPolicy applies when empty
+	PolicyFile string      `yaml:"policy_file"`
+	RateLimits []RateLimit `yaml:"rate_limits"`
+	// APIKeys are the keys API clients send in X-API-Key, api_key rate limits
+	// count requests with other keys against the client IP
+	APIKeys []string `yaml:"api_keys"`
+	// TrustedProxies are the networks X-Forwarded-For and X-Real-IP are accepted from
+	TrustedProxies []string    `yaml:"trusted_proxies"`
+	CORS           CORS        `yaml:"cors"`
+	Cache          Cache       `yaml:"cache"`
+	Coalescing     Coalescing  `yaml:"coalescing"`
+	Idempotency    Idempotency `yaml:"idempotency"`
+	Pagination     Pagination  `yaml:"pagination"`
+	Search         Search      `yaml:"search"`
+	Cart           Cart        `yaml:"cart"`
+	Orders         Orders      `yaml:"orders"`
+	Payments       Payments    `yaml:"payments"`
+	Breaker        Breaker     `yaml:"breaker"`
+	Retry          Retry       `yaml:"retry"`
+	Readiness      Readiness   `yaml:"readiness"`
+	Log            Log         `yaml:"log"`
+	Tracing        Tracing     `yaml:"tracing"`
+	Shutdown       Shutdown    `yaml:"shutdown"`
+	Reload         Reload      `yaml:"reload"`
+
+	// Source is the file the configuration was loaded from
+	Source string `yaml:"-"`
+}
+
+// Server configures the HTTP server of the gateway
+type Server struct {
+	Port              int           `yaml:"port"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+}
+
+// Load balancing strategies
+const (
+	BalancerRoundRobin       = "round_robin"
+	BalancerLeastConnections = "least_connections"
+	BalancerWeighted         = "weighted"
+	BalancerConsistentHash   = "consistent_hash"
+)
+
+// Upstream configures a backend service
+type Upstream struct {
+	// URL is the address of a service with a single instance
+	URL string `yaml:"url"`
+	// Instances replace URL for services with several instances
+	Instances []Instance `yaml:"instances"`
+	// Discovery finds the instances at runtime instead of URL and Instances
+	Discovery Discovery `yaml:"discovery"`
+	// Balancer is round_robin, least_connections, weighted or consistent_hash
+	Balancer string `yaml:"balancer"`
+	// HashHeader is the request header consistent_hash keys on
+	HashHeader string `yaml:"hash_header"`
+	// HealthPath is probed by the readiness and active health checks
+	HealthPath string `yaml:"health_path"`
+	// Critical upstreams make the gateway unready when they are down
+	Critical         bool             `yaml:"critical"`
+	Transport        Transport        `yaml:"transport"`
+	HealthCheck      HealthCheck      `yaml:"health_check"`
+	OutlierDetection OutlierDetection `yaml:"outlier_detection"`
+}
+
+// Instance is an address of an upstream service
+type Instance struct {
+	URL string `yaml:"url"`
+	// Weight is the share of requests of the instance for the weighted and
+	// consistent_hash balancers
+	Weight int `yaml:"weight"`
+}
+
+// Discovery providers
+const (
+	DiscoveryStatic = "static"
+	DiscoveryDNS    = "dns"
+	DiscoveryFile   = "file"
+)
+
+// DNS record types of service discovery
+const (
+	RecordSRV = "srv"
+	RecordA   = "a"
+)
+
+// Discovery configures how the instances of an upstream are found
+type Discovery struct {
+	// Provider is static for URL and Instances, dns or file
+	Provider string        `yaml:"provider"`
+	DNS      DNSDiscovery  `yaml:"dns"`
+	File     FileDiscovery `yaml:"file"`
+	// RetryInterval is the delay before a failed lookup is repeated, the
+	// known instances are kept meanwhile
+	RetryInterval time.Duration `yaml:"retry_interval"`
 }
 
-var App Config
+// DNSDiscovery resolves the instances from SRV or A/AAAA records
+type DNSDiscovery struct {
+	Name string `yaml:"name"`
+	// Record is srv or a, a also resolves AAAA records
+	Record string `yaml:"record"`
+	// Scheme of the instance URLs
+	Scheme string `yaml:"scheme"`
+	// Port of the instances for a records, srv records carry their own
+	Port int `yaml:"port"`
+	// Server is the nameserver address, the first one of /etc/resolv.conf by default
+	Server string `yaml:"server"`
+	// MinRefresh and MaxRefresh bound how long the record TTLs are trusted
+	MinRefresh time.Duration `yaml:"min_refresh"`
+	MaxRefresh time.Duration `yaml:"max_refresh"`
+}
+
+// FileDiscovery reads the instances from a registry file mapping upstream
+// names to lists of instances
+type FileDiscovery struct {
+	Path string `yaml:"path"`
+	// Interval between reads of the file
+	Interval time.Duration `yaml:"interval"`
+}
+
+// HealthCheck configures active probing of the instances of an upstream
+type HealthCheck struct {
+	// Interval between probes, 0 disables active health checks
+	Interval time.Duration `yaml:"interval"`
+	Timeout  time.Duration `yaml:"timeout"`
+	// UnhealthyThreshold failed probes in a row take an instance out of rotation
+	UnhealthyThreshold int `yaml:"unhealthy_threshold"`
+	// HealthyThreshold successful probes in a row put it back
+	HealthyThreshold int `yaml:"healthy_threshold"`
+}
+
+// OutlierDetection configures ejection of instances failing live traffic
+type OutlierDetection struct {
+	// Consecutive5xx failed responses in a row eject an instance, 0 disables ejection
+	Consecutive5xx int           `yaml:"consecutive_5xx"`
+	EjectionTime   time.Duration `yaml:"ejection_time"`
+	// MaxEjectionPercent limits the share of instances ejected at once
+	MaxEjectionPercent int `yaml:"max_ejection_percent"`
+}
+
+// Endpoints returns the instances of the upstream
+func (u *Upstream) Endpoints() []Instance {
+	if len(u.Instances) > 0 {
+		return u.Instances
+	}
+	return []Instance{{URL: u.URL, Weight: 1}}
+}
+
+// Transport configures connections to an upstream service
+type Transport struct {
+	DialTimeout           time.Duration `yaml:"dial_timeout"`
+	TLSHandshakeTimeout   time.Duration `yaml:"tls_handshake_timeout"`
+	ResponseHeaderTimeout time.Duration `yaml:"response_header_timeout"`
+	// Timeout limits the whole upstream call including reading the response body
+	Timeout             time.Duration `yaml:"timeout"`
+	MaxIdleConnsPerHost int           `yaml:"max_idle_conns_per_host"`
+	IdleConnTimeout     time.Duration `yaml:"idle_conn_timeout"`
+}
+
+// Route exposes an upstream endpoint through the gateway
+type Route struct {
+	Method string `yaml:"method"`
+	// Path is the gin path template of the gateway endpoint
+	Path     string `yaml:"path"`
+	Upstream string `yaml:"upstream"`
+	// Target is the upstream path template, defaults to Path
+	Target string `yaml:"target"`
+	// Coalesce shares upstream calls between identical concurrent GET requests
+	Coalesce bool `yaml:"coalesce"`
+}
+
+// Auth configures verification of access tokens issued by the auth service
+type Auth struct {
+	Algorithm     string `yaml:"algorithm"`
+	Secret        string `yaml:"secret"`
+	PublicKeyFile string `yaml:"public_key_file"`
+	// UpstreamSecret is sent to the upstreams with the identity headers,
+	// services trust the headers only together with it
+	UpstreamSecret string `yaml:"upstream_secret"`
+}
+
+// Breaker configures the circuit breakers of the upstreams
+type Breaker struct {
+	FailureThreshold int           `yaml:"failure_threshold"`
+	OpenTimeout      time.Duration `yaml:"open_timeout"`
+}
+
+// Retry configures retries of idempotent upstream calls
+type Retry struct {
+	Attempts  int           `yaml:"attempts"`
+	BaseDelay time.Duration `yaml:"base_delay"`
+	MaxDelay  time.Duration `yaml:"max_delay"`
+}
+
+// Readiness configures probing of the upstreams by /readyz
+type Readiness struct {
+	Timeout  time.Duration `yaml:"timeout"`
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+}
+
+// Log configures the gateway logs
+type Log struct {
+	// Level is debug, info, warn or error
+	Level string `yaml:"level"`
+	// Format is json or text
+	Format string `yaml:"format"`
+}
+
+// Tracing configures span export
+type Tracing struct {
+	// Exporter is none, stdout or otlp
+	Exporter string `yaml:"exporter"`
+	// Endpoint is the URL of the OTLP/HTTP collector
+	Endpoint    string  `yaml:"endpoint"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// Shutdown configures draining of the gateway on SIGTERM/SIGINT
+type Shutdown struct {
+	// Delay is how long readiness fails before the listener closes
+	Delay time.Duration `yaml:"delay"`
+	// GracePeriod limits draining of in-flight requests
+	GracePeriod time.Duration `yaml:"grace_period"`
+}
+
+// Cache configures the response cache of the product catalogue reads
+type Cache struct {
+	// Enabled caches GET /product/list and /product/info/:id
+	Enabled bool          `yaml:"enabled"`
+	TTL     time.Duration `yaml:"ttl"`
+	// MaxEntries bounds the number of cached responses
+	MaxEntries int `yaml:"max_entries"`
+}
+
+// Coalescing configures sharing of upstream calls between identical
+// concurrent requests of anonymous clients
+type Coalescing struct {
+	// Routes are the GET route templates to coalesce, routes from the routes
+	// section opt in with coalesce
+	Routes []string `yaml:"routes"`
+}
+
+// DefaultCoalescedRoutes are the public catalogue reads
+var DefaultCoalescedRoutes = []string{"/product/list", "/product/info/:id", "/product/verify/:name"}
+
+// Idempotency configures replaying of the responses of retried POST, PUT and
+// DELETE requests that carry an Idempotency-Key header
+type Idempotency struct {
+	Enabled bool `yaml:"enabled"`
+	// TTL is how long the response of a key is replayed
+	TTL time.Duration `yaml:"ttl"`
+	// MaxEntries bounds the number of keys kept
+	MaxEntries int `yaml:"max_entries"`
+	// MaxBodyBytes bounds the body of requests with a key, larger ones are
+	// rejected with 413
+	MaxBodyBytes int `yaml:"max_body_bytes"`
+}
+
+// Pagination configures the cursor paginated product list and the cursors
+// of the product search
+type Pagination struct {
+	// DefaultLimit is the page size of requests without a limit
+	DefaultLimit int `yaml:"default_limit"`
+	// MaxLimit caps the page size requested by clients
+	MaxLimit int `yaml:"max_limit"`
+	// CursorSecret signs the cursors. A random key is used when empty, the
+	// cursors are then only accepted by the instance that issued them until
+	// it restarts.
+	CursorSecret string `yaml:"cursor_secret"`
+}
+
+// Search backends
+const (
+	SearchMemory = "memory"
+)
+
+// Search configures the product search index
+type Search struct {
+	// Backend is where the index is kept, only memory is supported
+	Backend string `yaml:"backend"`
+	// RefreshInterval is how often the catalogue is reloaded from the product
+	// service, changes made through the gateway are indexed immediately
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+	// DefaultLimit is the page size of searches without a limit
+	DefaultLimit int `yaml:"default_limit"`
+	// MaxLimit caps the page size requested by clients
+	MaxLimit int `yaml:"max_limit"`
+}
+
+// Cart configures validation of cart items
+type Cart struct {
+	// MaxQuantity is the largest quantity of a cart item
+	MaxQuantity int `yaml:"max_quantity"`
+}
+
+// Order stores
+const (
+	OrdersMemory   = "memory"
+	OrdersPostgres = "postgres"
+)
+
+// Orders configures where orders and their payments are kept
+type Orders struct {
+	// Store is memory or postgres, orders in memory are lost on restart
+	Store string `yaml:"store"`
+	// DSN is the connection string of the postgres store
+	DSN string `yaml:"dsn"`
+}
+
+// Payment providers
+const (
+	PaymentsFake = "fake"
+)
+
+// Payments configures charging of orders
+type Payments struct {
+	// Provider charges the customers and must be set, fake charges nothing
+	Provider string `yaml:"provider"`
+	// Currency is the ISO 4217 code orders are charged in
+	Currency string `yaml:"currency"`
+	// Timeout of provider calls, charges that time out are settled by a webhook
+	Timeout time.Duration `yaml:"timeout"`
+	// WebhookSecret verifies the signatures of provider webhooks, webhooks
+	// are rejected without it
+	WebhookSecret string `yaml:"webhook_secret"`
+	// FakeOutcome is the outcome of fake charges: succeed, decline or
+	// timeout, it must be set for the fake provider
+	FakeOutcome string `yaml:"fake_outcome"`
+}
+
+// Reload configures watching of the config file and the policy file
+type Reload struct {
+	// Interval between checks of the files for changes, 0 disables them
+	Interval time.Duration `yaml:"interval"`
+}
+
+// DefaultTrustedProxies are the private networks nginx reaches the gateway from
+var DefaultTrustedProxies = []string{"127.0.0.0/8", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "::1/128", "fc00::/7"}
+
+// DefaultHealthCheck are the active health check settings of upstreams that do not override them
+var DefaultHealthCheck = HealthCheck{
+	Interval:           10 * time.Second,
+	Timeout:            2 * time.Second,
+	UnhealthyThreshold: 3,
+	HealthyThreshold:   2,
+}
+
+// DefaultOutlierDetection are the ejection settings of upstreams that do not override them
+var DefaultOutlierDetection = OutlierDetection{
+	Consecutive5xx:     5,
+	EjectionTime:       30 * time.Second,
+	MaxEjectionPercent: 50,
+}
+
+// DefaultDiscovery are the service discovery settings of upstreams that do not override them
+var DefaultDiscovery = Discovery{
+	Provider:      DiscoveryStatic,
+	DNS:           DNSDiscovery{Record: RecordSRV, Scheme: "http", MinRefresh: 5 * time.Second, MaxRefresh: time.Minute},
+	File:          FileDiscovery{Interval: 5 * time.Second},
+	RetryInterval: 5 * time.Second,
+}
+
+// DefaultTransport are the connection settings of upstreams that do not override them
+var DefaultTransport = Transport{
+	DialTimeout:           5 * time.Second,
+	TLSHandshakeTimeout:   5 * time.Second,
+	ResponseHeaderTimeout: 10 * time.Second,
+	Timeout:               30 * time.Second,
+	MaxIdleConnsPerHost:   32,
+	IdleConnTimeout:       90 * time.Second,
+}
+
+// Default returns the built-in configuration
+func Default() *Config {
+	rateLimits, err := ParseRateLimits(DefaultRateLimits)
+	if err != nil {
+		panic(err)
+	}
+
+	cart := newUpstream("http://cart:8003", "/healthcheck", false)
+	// Carts of a user stay on one instance
+	cart.Balancer = BalancerConsistentHash
+
+	return &Config{
+		Server: Server{
+			Port:              8000,
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       30 * time.Second,
+			WriteTimeout:      60 * time.Second,
+			IdleTimeout:       120 * time.Second,
+		},
+		Upstreams: map[string]*Upstream{
+			"auth":    newUpstream("http://auth:8002", "/auth/healthcheck", true),
+			"product": newUpstream("http://product:8001", "/product/healthcheck", true),
+			"cart":    cart,
+		},
+		Auth:           Auth{Algorithm: "HS256"},
+		RateLimits:     rateLimits,
+		TrustedProxies: append([]string(nil), DefaultTrustedProxies...),
+		CORS:           DefaultCORS(),
+		Cache:          Cache{Enabled: true, TTL: 30 * time.Second, MaxEntries: 1000},
+		Coalescing:     Coalescing{Routes: append([]string(nil), DefaultCoalescedRoutes...)},
+		Idempotency:    Idempotency{Enabled: true, TTL: 24 * time.Hour, MaxEntries: 10000, MaxBodyBytes: 1 << 20},
+		Pagination:     Pagination{DefaultLimit: 20, MaxLimit: 100},
+		Search:         Search{Backend: SearchMemory, RefreshInterval: 5 * time.Minute, DefaultLimit: 20, MaxLimit: 100},
+		Cart:           Cart{MaxQuantity: 99},
+		Orders:         Orders{Store: OrdersMemory},
+		Payments:       Payments{Currency: "USD", Timeout: 10 * time.Second},
+		Breaker:        Breaker{FailureThreshold: 5, OpenTimeout: 30 * time.Second},
+		Retry:          Retry{Attempts: 3, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second},
+		Readiness:      Readiness{Timeout: 2 * time.Second, CacheTTL: 5 * time.Second},
+		Log:            Log{Level: "info", Format: "json"},
+		Tracing:        Tracing{Exporter: "none", SampleRatio: 1},
+		Shutdown:       Shutdown{Delay: 5 * time.Second, GracePeriod: 20 * time.Second},
+		Reload:         Reload{Interval: 5 * time.Second},
+	}
+}
+
+// newUpstream returns an upstream with the default health check and outlier
+// detection settings
+func newUpstream(url, healthPath string, critical bool) *Upstream {
+	upstream := &Upstream{
+		URL:              url,
+		HealthPath:       healthPath,
+		Critical:         critical,
+		HealthCheck:      DefaultHealthCheck,
+		OutlierDetection: DefaultOutlierDetection,
+	}
+	return upstream
+}
+
+// Load builds the configuration from the defaults, the config file, the
+// environment and the command line arguments and validates it
+func Load(args []string) (*Config, error) {
+	cfg, err := load(args)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return cfg, nil
+}
+
+// load builds the configuration without validating it
+func load(args []string) (*Config, error) {
+	flags, err := parseFlags(args)
+	if err != nil {
+		return nil, err
+	}
+
+	path := flags.configFile
+	if path == "" {
+		path = os.Getenv("GATEWAY_CONFIG")
+	}
+
+	cfg := Default()
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+		cfg.Source = path
+	}
+	if err := cfg.loadEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	flags.apply(cfg)
+	cfg.applyDefaults()
+	return cfg, nil
+}
+
+// loadFile merges the YAML file at path into the configuration
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config: %w", err)
+	}
+
+	defaults := make(map[string]*Upstream, len(c.Upstreams))
+	for name, upstream := range c.Upstreams {
+		defaults[name] = upstream
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parse config %s: %w", path, err)
+	}
+
+	// Decoding replaced the upstreams named in the file, decode them once
+	// more on top of their defaults so that omitted fields keep them
+	var raw struct {
+		Upstreams map[string]yaml.Node `yaml:"upstreams"`
+	}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("parse config %s: %w", path, err)
+	}
+	if c.Upstreams == nil {
+		c.Upstreams = make(map[string]*Upstream)
+	}
+	for name, upstream := range defaults {
+		if _, ok := raw.Upstreams[name]; !ok {
+			c.Upstreams[name] = upstream
+		}
+	}
+	for name, node := range raw.Upstreams {
+		merged := newUpstream("", "", false)
+		if upstream, ok := defaults[name]; ok {
+			copied := *upstream
+			merged = &copied
+		}
+		if err := node.Decode(merged); err != nil {
+			return fmt.Errorf("parse config %s: upstream %s: %w", path, name, err)
+		}
+		c.Upstreams[name] = merged
+	}
+	return nil
+}
+
+// applyDefaults fills settings that are left empty
+func (c *Config) applyDefaults() {
+	if c.Auth.Algorithm == "" {
+		c.Auth.Algorithm = "HS256"
+	}
+	for _, upstream := range c.Upstreams {
+		if len(upstream.Instances) > 0 {
+			upstream.URL = ""
+		}
+		for i := range upstream.Instances {
+			if upstream.Instances[i].Weight == 0 {
+				upstream.Instances[i].Weight = 1
+			}
+		}
+		if upstream.Balancer == "" {
+			upstream.Balancer = BalancerRoundRobin
+		}
+		if upstream.HashHeader == "" {
+			upstream.HashHeader = "X-User-Id"
+		}
+
+		d := &upstream.Discovery
+		setString(&d.Provider, DefaultDiscovery.Provider)
+		setString(&d.DNS.Record, DefaultDiscovery.DNS.Record)
+		setString(&d.DNS.Scheme, DefaultDiscovery.DNS.Scheme)
+		setDuration(&d.DNS.MinRefresh, DefaultDiscovery.DNS.MinRefresh)
+		setDuration(&d.DNS.MaxRefresh, DefaultDiscovery.DNS.MaxRefresh)
+		setDuration(&d.File.Interval, DefaultDiscovery.File.Interval)
+		setDuration(&d.RetryInterval, DefaultDiscovery.RetryInterval)
+
+		h := &upstream.HealthCheck
+		setDuration(&h.Timeout, DefaultHealthCheck.Timeout)
+		setInt(&h.UnhealthyThreshold, DefaultHealthCheck.UnhealthyThreshold)
+		setInt(&h.HealthyThreshold, DefaultHealthCheck.HealthyThreshold)
+
+		o := &upstream.OutlierDetection
+		setDuration(&o.EjectionTime, DefaultOutlierDetection.EjectionTime)
+		setInt(&o.MaxEjectionPercent, DefaultOutlierDetection.MaxEjectionPercent)
+
+		t := &upstream.Transport
+		setDuration(&t.DialTimeout, DefaultTransport.DialTimeout)
+		setDuration(&t.TLSHandshakeTimeout, DefaultTransport.TLSHandshakeTimeout)
+		setDuration(&t.ResponseHeaderTimeout, DefaultTransport.ResponseHeaderTimeout)
+		setDuration(&t.Timeout, DefaultTransport.Timeout)
+		setDuration(&t.IdleConnTimeout, DefaultTransport.IdleConnTimeout)
+		setInt(&t.MaxIdleConnsPerHost, DefaultTransport.MaxIdleConnsPerHost)
+	}
+}
+
+func setDuration(d *time.Duration, fallback time.Duration) {
+	if *d == 0 {
+		*d = fallback
+	}
+}
+
+func setString(s *string, fallback string) {
+	if *s == "" {
+		*s = fallback
+	}
+}
 
-func init() {
-	App = Config{
-		Gateway_port:        os.Getenv("GATEWAY_PORT"),
-		Auth_service_url:    os.Getenv("AuthServiceUrl"),
-		Product_service_url: os.Getenv("ProductServiceUrl"),
-		Cart_service_url:    os.Getenv("Cart_service_url"),
+func setInt(n *int, fallback int) {
+	if *n == 0 {
+		*n = fallback
 	}
 }
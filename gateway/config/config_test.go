@@ -0,0 +1,61 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultReloadInterval(t *testing.T) {
+	if got := Default().Reload.Interval; got != 5*time.Second {
+		t.Errorf("reload.interval = %s, want 5s so that the config and policy files are watched", got)
+	}
+}
+
+func TestExampleShowsDefaults(t *testing.T) {
+	example := Default()
+	if err := example.loadFile("../config.example.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	example.applyDefaults()
+	defaults := Default()
+	defaults.applyDefaults()
+
+	for _, change := range Diff(defaults, example) {
+		// The example lists the default product url as its single instance
+		if strings.HasPrefix(change, "upstreams.product.url:") || strings.HasPrefix(change, "upstreams.product.instances[0].") {
+			continue
+		}
+		t.Errorf("config.example.yaml differs from Default(): %s", change)
+	}
+}
+
+func TestValidatePaymentProvider(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		outcome  string
+		wantErr  string
+	}{
+		{"default", "", "", "payments.provider is required"},
+		{"fake without outcome", PaymentsFake, "", "payments.fake_outcome is required"},
+		{"fake with unknown outcome", PaymentsFake, "approve", "payments.fake_outcome must be"},
+		{"unknown provider", "stripe", "", "payments.provider must be fake"},
+		{"explicit fake", PaymentsFake, "decline", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.Auth.Secret, cfg.Auth.UpstreamSecret = "test", "test"
+			cfg.Payments.Provider, cfg.Payments.FakeOutcome = tt.provider, tt.outcome
+			cfg.applyDefaults()
+			err := cfg.Validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Validate() error = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// AnyOrigin allows requests from every origin, it cannot be combined with credentials
+const AnyOrigin = "*"
+
+// CORS configures which browser origins may call the gateway
+type CORS struct {
+	// AllowedOrigins are exact origins such as https://shop.example.com or
+	// wildcard subdomains such as https://*.example.com
+	AllowedOrigins []string `yaml:"allowed_origins"`
+	// AllowedOriginPatterns are regular expressions matched against the whole origin
+	AllowedOriginPatterns []string `yaml:"allowed_origin_patterns"`
+	AllowedMethods        []string `yaml:"allowed_methods"`
+	AllowedHeaders        []string `yaml:"allowed_headers"`
+	// ExposedHeaders are the response headers readable by scripts
+	ExposedHeaders []string `yaml:"exposed_headers"`
+	// AllowCredentials lets browsers send the access_token cookie
+	AllowCredentials bool `yaml:"allow_credentials"`
+	// MaxAge is how long browsers may cache preflight responses
+	MaxAge time.Duration `yaml:"max_age"`
+	// Overrides change the settings for route groups
+	Overrides []CORSOverride `yaml:"overrides"`
+}
+
+// CORSOverride replaces the settings it sets for requests whose path starts
+// with Path, settings left out are inherited
+type CORSOverride struct {
+	Path                  string         `yaml:"path"`
+	AllowedOrigins        []string       `yaml:"allowed_origins"`
+	AllowedOriginPatterns []string       `yaml:"allowed_origin_patterns"`
+	AllowedMethods        []string       `yaml:"allowed_methods"`
+	AllowedHeaders        []string       `yaml:"allowed_headers"`
+	ExposedHeaders        []string       `yaml:"exposed_headers"`
+	AllowCredentials      *bool          `yaml:"allow_credentials"`
+	MaxAge                *time.Duration `yaml:"max_age"`
+}
+
+// DefaultCORS allows the web client served by nginx and the Vite dev server
+func DefaultCORS() CORS {
+	return CORS{
+		AllowedOrigins: []string{"http://localhost", "https://localhost", "http://localhost:5173"},
+		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders: []string{
+			"Accept", "Authorization", "Cache-Control", "Content-Type",
+			"X-CSRF-Token", "X-Requested-With", "X-Request-ID", "Idempotency-Key",
+		},
+		ExposedHeaders: []string{
+			"X-Request-ID", "Retry-After", "Idempotent-Replayed", "Link", "Deprecation",
+			"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset",
+		},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+}
+
+// Override returns the settings for the route group of o
+func (c CORS) Override(o CORSOverride) CORS {
+	merged := c
+	merged.Overrides = nil
+	if o.AllowedOrigins != nil {
+		merged.AllowedOrigins = o.AllowedOrigins
+	}
+	if o.AllowedOriginPatterns != nil {
+		merged.AllowedOriginPatterns = o.AllowedOriginPatterns
+	}
+	if o.AllowedMethods != nil {
+		merged.AllowedMethods = o.AllowedMethods
+	}
+	if o.AllowedHeaders != nil {
+		merged.AllowedHeaders = o.AllowedHeaders
+	}
+	if o.ExposedHeaders != nil {
+		merged.ExposedHeaders = o.ExposedHeaders
+	}
+	if o.AllowCredentials != nil {
+		merged.AllowCredentials = *o.AllowCredentials
+	}
+	if o.MaxAge != nil {
+		merged.MaxAge = *o.MaxAge
+	}
+	return merged
+}
+
+// validate reports the invalid settings of the policy named name
+func (c CORS) validate(name string) []error {
+	var errs []error
+	for _, origin := range c.AllowedOrigins {
+		if origin == AnyOrigin {
+			if c.AllowCredentials {
+				errs = append(errs, fmt.Errorf("%s.allowed_origins: %q cannot be combined with allow_credentials", name, AnyOrigin))
+			}
+			continue
+		}
+		if err := validOrigin(origin); err != nil {
+			errs = append(errs, fmt.Errorf("%s.allowed_origins: %w", name, err))
+		}
+	}
+	for _, pattern := range c.AllowedOriginPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			errs = append(errs, fmt.Errorf("%s.allowed_origin_patterns: %w", name, err))
+		}
+	}
+	for _, method := range c.AllowedMethods {
+		if !validMethod(method) {
+			errs = append(errs, fmt.Errorf("%s.allowed_methods: %q is not an HTTP method", name, method))
+		}
+	}
+	if c.MaxAge < 0 {
+		errs = append(errs, fmt.Errorf("%s.max_age must not be negative", name))
+	}
+	return errs
+}
+
+// validOrigin checks an exact or wildcard subdomain origin
+func validOrigin(origin string) error {
+	host := origin
+	if scheme, rest, ok := strings.Cut(origin, "://"); ok {
+		host = rest
+		if strings.HasPrefix(host, "*.") {
+			host = "wildcard." + strings.TrimPrefix(host, "*.")
+		}
+		host = scheme + "://" + host
+	}
+
+	u, err := url.Parse(host)
+	if err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" || u.RawQuery != "" || u.User != nil {
+		return fmt.Errorf("%q is not an origin, expected <scheme>://<host>[:<port>]", origin)
+	}
+	if strings.Contains(u.Host, "*") {
+		return fmt.Errorf("%q: only a leading *. subdomain wildcard is supported", origin)
+	}
+	return nil
+}
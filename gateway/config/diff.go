@@ -0,0 +1,128 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// secretSettings are never shown in documents and diffs
+var secretSettings = map[string]bool{"auth.secret": true, "auth.upstream_secret": true, "orders.dsn": true, "payments.webhook_secret": true, "pagination.cursor_secret": true, "api_keys": true}
+
+const redacted = "<redacted>"
+
+// Document returns the configuration as a YAML shaped tree with secrets redacted
+func (c *Config) Document() map[string]interface{} {
+	tree := c.tree()
+	if auth, ok := tree["auth"].(map[string]interface{}); ok {
+		if auth["secret"] != "" {
+			auth["secret"] = redacted
+		}
+		if auth["upstream_secret"] != "" {
+			auth["upstream_secret"] = redacted
+		}
+	}
+	if orders, ok := tree["orders"].(map[string]interface{}); ok && orders["dsn"] != "" {
+		orders["dsn"] = redacted
+	}
+	if payments, ok := tree["payments"].(map[string]interface{}); ok && payments["webhook_secret"] != "" {
+		payments["webhook_secret"] = redacted
+	}
+	if pagination, ok := tree["pagination"].(map[string]interface{}); ok && pagination["cursor_secret"] != "" {
+		pagination["cursor_secret"] = redacted
+	}
+	if keys, ok := tree["api_keys"].([]interface{}); ok {
+		for i := range keys {
+			keys[i] = redacted
+		}
+	}
+	return tree
+}
+
+// Checksum identifies the content of the configuration and of the policy
+// file it refers to
+func (c *Config) Checksum() string {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+	hash := sha256.New()
+	hash.Write(data)
+	hash.Write([]byte(fileChecksum(c.PolicyFile)))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Diff lists the settings that differ between old and new, one per line in
+// the form <setting>: <old> -> <new>
+func Diff(old, new *Config) []string {
+	before, after := flatten(old.tree()), flatten(new.tree())
+
+	keys := make(map[string]bool, len(before)+len(after))
+	for key := range before {
+		keys[key] = true
+	}
+	for key := range after {
+		keys[key] = true
+	}
+
+	var diff []string
+	for key := range keys {
+		a, inOld := before[key]
+		b, inNew := after[key]
+		if inOld && inNew && a == b {
+			continue
+		}
+		// Entries of secret lists such as api_keys[0] are secret as well
+		if setting, _, _ := strings.Cut(key, "["); secretSettings[setting] {
+			a, b = redacted, redacted
+		}
+		if !inOld {
+			a = "<unset>"
+		}
+		if !inNew {
+			b = "<unset>"
+		}
+		diff = append(diff, fmt.Sprintf("%s: %s -> %s", key, a, b))
+	}
+	sort.Strings(diff)
+	return diff
+}
+
+// tree converts the configuration to nested maps by the YAML setting names
+func (c *Config) tree() map[string]interface{} {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+	tree := make(map[string]interface{})
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		panic(err)
+	}
+	return tree
+}
+
+// flatten maps dotted setting paths to their values
+func flatten(tree map[string]interface{}) map[string]string {
+	values := make(map[string]string)
+	var walk func(prefix string, node interface{})
+	walk = func(prefix string, node interface{}) {
+		switch node := node.(type) {
+		case map[string]interface{}:
+			for key, child := range node {
+				walk(strings.TrimPrefix(prefix+"."+key, "."), child)
+			}
+		case []interface{}:
+			for i, child := range node {
+				walk(fmt.Sprintf("%s[%d]", prefix, i), child)
+			}
+		default:
+			values[prefix] = fmt.Sprintf("%v", node)
+		}
+	}
+	walk("", tree)
+	return values
+}
@@ -0,0 +1,228 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// legacyUpstreamURLs are the variables the upstream URLs were read from
+// before <SERVICE>_SERVICE_URL, they apply when the new name is not set
+var legacyUpstreamURLs = map[string]string{
+	"auth":    "AuthServiceUrl",
+	"product": "ProductServiceUrl",
+	"cart":    "Cart_service_url",
+}
+
+// env reads typed settings from environment variables and remembers the first
+// invalid value
+type env struct {
+	lookup func(string) (string, bool)
+	err    error
+}
+
+// loadEnv overrides the configuration with the environment variables set
+func (c *Config) loadEnv(lookup func(string) (string, bool)) error {
+	e := &env{lookup: lookup}
+
+	e.int(&c.Server.Port, "PORT", "GATEWAY_PORT")
+	e.duration(&c.Server.ReadHeaderTimeout, "SERVER_READ_HEADER_TIMEOUT")
+	e.duration(&c.Server.ReadTimeout, "SERVER_READ_TIMEOUT")
+	e.duration(&c.Server.WriteTimeout, "SERVER_WRITE_TIMEOUT")
+	e.duration(&c.Server.IdleTimeout, "SERVER_IDLE_TIMEOUT")
+
+	for name, upstream := range c.Upstreams {
+		prefix := strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+		keys := []string{prefix + "_SERVICE_URL"}
+		if legacy, ok := legacyUpstreamURLs[name]; ok {
+			keys = append(keys, legacy)
+		}
+		if value, ok := e.get(keys...); ok {
+			upstream.setAddress(value)
+		}
+		e.string(&upstream.Discovery.Provider, prefix+"_DISCOVERY")
+		e.string(&upstream.Discovery.DNS.Name, prefix+"_DNS_NAME")
+		e.string(&upstream.Discovery.DNS.Record, prefix+"_DNS_RECORD")
+		e.int(&upstream.Discovery.DNS.Port, prefix+"_DNS_PORT")
+		e.string(&upstream.Discovery.File.Path, prefix+"_REGISTRY_FILE")
+		e.string(&upstream.Balancer, prefix+"_BALANCER")
+		e.transport(&upstream.Transport, "UPSTREAM_")
+		e.transport(&upstream.Transport, prefix+"_UPSTREAM_")
+	}
+
+	e.string(&c.Auth.Algorithm, "AUTH_ALGORITHM")
+	e.string(&c.Auth.Secret, "AUTH_SECRET_KEY")
+	e.string(&c.Auth.PublicKeyFile, "AUTH_PUBLIC_KEY_FILE")
+	e.string(&c.Auth.UpstreamSecret, "GATEWAY_UPSTREAM_SECRET")
+	e.string(&c.PolicyFile, "RBAC_POLICY_FILE")
+
+	if value, ok := e.get("RATE_LIMITS"); ok {
+		limits, err := ParseRateLimits(value)
+		e.fail("RATE_LIMITS", value, err)
+		c.RateLimits = limits
+	}
+	e.list(&c.APIKeys, "API_KEYS")
+	e.list(&c.TrustedProxies, "TRUSTED_PROXIES")
+
+	e.list(&c.CORS.AllowedOrigins, "CORS_ALLOWED_ORIGINS")
+	e.list(&c.CORS.AllowedOriginPatterns, "CORS_ALLOWED_ORIGIN_PATTERNS")
+	e.list(&c.CORS.AllowedMethods, "CORS_ALLOWED_METHODS")
+	e.list(&c.CORS.AllowedHeaders, "CORS_ALLOWED_HEADERS")
+	e.list(&c.CORS.ExposedHeaders, "CORS_EXPOSED_HEADERS")
+	e.bool(&c.CORS.AllowCredentials, "CORS_ALLOW_CREDENTIALS")
+	e.duration(&c.CORS.MaxAge, "CORS_MAX_AGE")
+
+	e.bool(&c.Cache.Enabled, "CACHE_ENABLED")
+	e.duration(&c.Cache.TTL, "CACHE_TTL")
+	e.int(&c.Cache.MaxEntries, "CACHE_MAX_ENTRIES")
+	e.list(&c.Coalescing.Routes, "COALESCE_ROUTES")
+	e.bool(&c.Idempotency.Enabled, "IDEMPOTENCY_ENABLED")
+	e.duration(&c.Idempotency.TTL, "IDEMPOTENCY_TTL")
+	e.int(&c.Idempotency.MaxEntries, "IDEMPOTENCY_MAX_ENTRIES")
+	e.int(&c.Idempotency.MaxBodyBytes, "IDEMPOTENCY_MAX_BODY_BYTES")
+	e.int(&c.Pagination.DefaultLimit, "PAGINATION_DEFAULT_LIMIT")
+	e.int(&c.Pagination.MaxLimit, "PAGINATION_MAX_LIMIT")
+	e.string(&c.Pagination.CursorSecret, "PAGINATION_CURSOR_SECRET")
+	e.string(&c.Search.Backend, "SEARCH_BACKEND")
+	e.duration(&c.Search.RefreshInterval, "SEARCH_REFRESH_INTERVAL")
+	e.int(&c.Search.DefaultLimit, "SEARCH_DEFAULT_LIMIT")
+	e.int(&c.Search.MaxLimit, "SEARCH_MAX_LIMIT")
+	e.int(&c.Cart.MaxQuantity, "CART_MAX_QUANTITY")
+	e.string(&c.Orders.Store, "ORDERS_STORE")
+	e.string(&c.Orders.DSN, "ORDERS_DATABASE_URL")
+	e.string(&c.Payments.Provider, "PAYMENTS_PROVIDER")
+	e.string(&c.Payments.Currency, "PAYMENTS_CURRENCY")
+	e.duration(&c.Payments.Timeout, "PAYMENTS_TIMEOUT")
+	e.string(&c.Payments.WebhookSecret, "PAYMENTS_WEBHOOK_SECRET")
+	e.string(&c.Payments.FakeOutcome, "PAYMENTS_FAKE_OUTCOME")
+
+	e.int(&c.Breaker.FailureThreshold, "BREAKER_FAILURE_THRESHOLD")
+	e.duration(&c.Breaker.OpenTimeout, "BREAKER_OPEN_TIMEOUT")
+	e.int(&c.Retry.Attempts, "RETRY_ATTEMPTS")
+	e.duration(&c.Retry.BaseDelay, "RETRY_BASE_DELAY")
+	e.duration(&c.Retry.MaxDelay, "RETRY_MAX_DELAY")
+
+	if value, ok := e.get("READINESS_CRITICAL_UPSTREAMS"); ok {
+		critical := make(map[string]bool)
+		for _, name := range splitList(value) {
+			critical[name] = true
+		}
+		for name, upstream := range c.Upstreams {
+			upstream.Critical = critical[name]
+		}
+	}
+	e.duration(&c.Readiness.Timeout, "READINESS_TIMEOUT")
+	e.duration(&c.Readiness.CacheTTL, "READINESS_CACHE_TTL")
+
+	e.string(&c.Log.Level, "LOG_LEVEL")
+	e.string(&c.Log.Format, "LOG_FORMAT")
+
+	e.string(&c.Tracing.Exporter, "TRACING_EXPORTER")
+	e.string(&c.Tracing.Endpoint, "TRACING_OTLP_ENDPOINT")
+	e.float(&c.Tracing.SampleRatio, "TRACING_SAMPLE_RATIO")
+
+	e.duration(&c.Shutdown.Delay, "SHUTDOWN_DELAY")
+	e.duration(&c.Shutdown.GracePeriod, "SHUTDOWN_GRACE_PERIOD")
+	e.duration(&c.Reload.Interval, "CONFIG_RELOAD_INTERVAL")
+
+	return e.err
+}
+
+// setAddress replaces the instances of the upstream with the URL or comma
+// separated list of URLs in value
+func (u *Upstream) setAddress(value string) {
+	u.Discovery.Provider = DiscoveryStatic
+	urls := splitList(value)
+	if len(urls) == 1 {
+		u.URL, u.Instances = urls[0], nil
+		return
+	}
+	u.URL, u.Instances = "", nil
+	for _, url := range urls {
+		u.Instances = append(u.Instances, Instance{URL: url, Weight: 1})
+	}
+}
+
+// transport overrides the transport settings from <prefix>* variables
+func (e *env) transport(t *Transport, prefix string) {
+	e.duration(&t.DialTimeout, prefix+"DIAL_TIMEOUT")
+	e.duration(&t.TLSHandshakeTimeout, prefix+"TLS_HANDSHAKE_TIMEOUT")
+	e.duration(&t.ResponseHeaderTimeout, prefix+"RESPONSE_HEADER_TIMEOUT")
+	e.duration(&t.Timeout, prefix+"TIMEOUT")
+	e.int(&t.MaxIdleConnsPerHost, prefix+"MAX_IDLE_CONNS_PER_HOST")
+	e.duration(&t.IdleConnTimeout, prefix+"IDLE_CONN_TIMEOUT")
+}
+
+// get returns the value of the first variable set among keys
+func (e *env) get(keys ...string) (string, bool) {
+	for _, key := range keys {
+		if value, ok := e.lookup(key); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+func (e *env) string(dst *string, keys ...string) {
+	if value, ok := e.get(keys...); ok {
+		*dst = value
+	}
+}
+
+// list reads a comma separated list
+func (e *env) list(dst *[]string, keys ...string) {
+	if value, ok := e.get(keys...); ok {
+		*dst = splitList(value)
+	}
+}
+
+func (e *env) bool(dst *bool, keys ...string) {
+	if value, ok := e.get(keys...); ok {
+		b, err := strconv.ParseBool(value)
+		e.fail(keys[0], value, err)
+		*dst = b
+	}
+}
+
+func (e *env) int(dst *int, keys ...string) {
+	if value, ok := e.get(keys...); ok {
+		n, err := strconv.Atoi(value)
+		e.fail(keys[0], value, err)
+		*dst = n
+	}
+}
+
+func (e *env) float(dst *float64, keys ...string) {
+	if value, ok := e.get(keys...); ok {
+		f, err := strconv.ParseFloat(value, 64)
+		e.fail(keys[0], value, err)
+		*dst = f
+	}
+}
+
+func (e *env) duration(dst *time.Duration, keys ...string) {
+	if value, ok := e.get(keys...); ok {
+		d, err := time.ParseDuration(value)
+		e.fail(keys[0], value, err)
+		*dst = d
+	}
+}
+
+// fail records the first invalid value
+func (e *env) fail(key, value string, err error) {
+	if err != nil && e.err == nil {
+		e.err = fmt.Errorf("invalid value %q of %s: %w", value, key, err)
+	}
+}
+
+// splitList splits a comma separated list dropping empty items
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+)
+
+// cliFlags are the command line flags of the gateway
+type cliFlags struct {
+	set *flag.FlagSet
+
+	configFile string
+	port       int
+	authURL    string
+	productURL string
+	cartURL    string
+	policyFile string
+	logLevel   string
+	logFormat  string
+	tracing    string
+}
+
+// parseFlags parses the command line arguments
+func parseFlags(args []string) (*cliFlags, error) {
+	f := &cliFlags{set: flag.NewFlagSet("gateway", flag.ContinueOnError)}
+	f.set.StringVar(&f.configFile, "config", "", "path to the YAML configuration file (env GATEWAY_CONFIG)")
+	f.set.IntVar(&f.port, "port", 0, "port to listen on (env PORT)")
+	f.set.StringVar(&f.authURL, "auth-url", "", "URLs of the auth service instances, comma separated (env AUTH_SERVICE_URL)")
+	f.set.StringVar(&f.productURL, "product-url", "", "URLs of the product service instances, comma separated (env PRODUCT_SERVICE_URL)")
+	f.set.StringVar(&f.cartURL, "cart-url", "", "URLs of the cart service instances, comma separated (env CART_SERVICE_URL)")
+	f.set.StringVar(&f.policyFile, "policy-file", "", "path to the access policy (env RBAC_POLICY_FILE)")
+	f.set.StringVar(&f.logLevel, "log-level", "", "debug, info, warn or error (env LOG_LEVEL)")
+	f.set.StringVar(&f.logFormat, "log-format", "", "json or text (env LOG_FORMAT)")
+	f.set.StringVar(&f.tracing, "tracing-exporter", "", "none, stdout or otlp (env TRACING_EXPORTER)")
+
+	if err := f.set.Parse(args); err != nil {
+		return nil, err
+	}
+	if f.set.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments %q", f.set.Args())
+	}
+	return f, nil
+}
+
+// apply overrides the configuration with the flags given on the command line
+func (f *cliFlags) apply(c *Config) {
+	f.set.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "port":
+			c.Server.Port = f.port
+		case "auth-url":
+			c.upstream("auth").setAddress(f.authURL)
+		case "product-url":
+			c.upstream("product").setAddress(f.productURL)
+		case "cart-url":
+			c.upstream("cart").setAddress(f.cartURL)
+		case "policy-file":
+			c.PolicyFile = f.policyFile
+		case "log-level":
+			c.Log.Level = f.logLevel
+		case "log-format":
+			c.Log.Format = f.logFormat
+		case "tracing-exporter":
+			c.Tracing.Exporter = f.tracing
+		}
+	})
+}
+
+// upstream returns the upstream name, adding it when it is not configured
+func (c *Config) upstream(name string) *Upstream {
+	if c.Upstreams[name] == nil {
+		if c.Upstreams == nil {
+			c.Upstreams = make(map[string]*Upstream)
+		}
+		c.Upstreams[name] = &Upstream{}
+	}
+	return c.Upstreams[name]
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Policy maps gateway routes to the roles required to access them
+type Policy struct {
+	// DefaultRoles are granted to every authenticated user
+	DefaultRoles []string `yaml:"default_roles"`
+	// Users grants additional roles to users by the id the auth service
+	// issued them. Usernames are chosen at registration and never grant roles.
+	Users map[string][]string `yaml:"users"`
+	// Rules are checked in order, the first matching rule applies
+	Rules []PolicyRule `yaml:"rules"`
+}
+
+// PolicyRule requires one of Roles for requests matching Methods and Path
+type PolicyRule struct {
+	// Methods the rule applies to, any method when empty
+	Methods []string `yaml:"methods"`
+	// Path is a gin route template such as /cart/update/:item_id.
+	// A trailing * matches every route starting with the prefix.
+	Path string `yaml:"path"`
+	// Roles the user needs at least one of
+	Roles []string `yaml:"roles"`
+}
+
+// DefaultPolicy is used when no policy file is configured. It restricts
+// catalogue changes and the admin API to admins and carts, orders and
+// payments to customers.
+func DefaultPolicy() *Policy {
+	return &Policy{
+		DefaultRoles: []string{"customer"},
+		Rules: []PolicyRule{
+			{Methods: []string{"POST"}, Path: "/product/add", Roles: []string{"admin"}},
+			{Methods: []string{"PUT"}, Path: "/product/update/:id", Roles: []string{"admin"}},
+			{Path: "/cart*", Roles: []string{"customer", "admin"}},
+			{Path: "/orders*", Roles: []string{"customer", "admin"}},
+			{Path: "/payments*", Roles: []string{"customer", "admin"}},
+			{Path: "/admin*", Roles: []string{"admin"}},
+		},
+	}
+}
+
+// LoadPolicy reads the policy file at path, falling back to DefaultPolicy
+// when path is empty
+func LoadPolicy(path string) (*Policy, error) {
+	if path == "" {
+		return DefaultPolicy(), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read policy: %w", err)
+	}
+
+	var policy Policy
+	if err := yaml.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("parse policy %s: %w", path, err)
+	}
+
+	for user := range policy.Users {
+		if id, err := strconv.ParseInt(user, 10, 64); err != nil || id <= 0 {
+			return nil, fmt.Errorf("policy %s: users are mapped by user id, got %q", path, user)
+		}
+	}
+	for i, rule := range policy.Rules {
+		if rule.Path == "" {
+			return nil, fmt.Errorf("policy %s: rule %d has no path", path, i+1)
+		}
+		if len(rule.Roles) == 0 {
+			return nil, fmt.Errorf("policy %s: rule %d for %s has no roles", path, i+1, rule.Path)
+		}
+	}
+
+	return &policy, nil
+}
+
+// RequiredRoles returns the roles of the first rule matching the route. The
+// second result is false when no rule matches and the route is unrestricted.
+func (p *Policy) RequiredRoles(method, route string) ([]string, bool) {
+	for _, rule := range p.Rules {
+		if rule.matches(method, route) {
+			return rule.Roles, true
+		}
+	}
+	return nil, false
+}
+
+// UserRoles returns the roles granted to the user with the id in addition to
+// tokenRoles
+func (p *Policy) UserRoles(userID string, tokenRoles []string) []string {
+	roles := make([]string, 0, len(p.DefaultRoles)+len(tokenRoles))
+	roles = append(roles, p.DefaultRoles...)
+	roles = append(roles, tokenRoles...)
+	if userID != "" {
+		roles = append(roles, p.Users[userID]...)
+	}
+	return roles
+}
+
+func (r PolicyRule) matches(method, route string) bool {
+	if len(r.Methods) > 0 {
+		found := false
+		for _, m := range r.Methods {
+			if strings.EqualFold(m, method) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if prefix, ok := strings.CutSuffix(r.Path, "*"); ok {
+		return strings.HasPrefix(route, prefix)
+	}
+	return route == r.Path
+}
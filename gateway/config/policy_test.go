@@ -0,0 +1,55 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPolicyUserRoles(t *testing.T) {
+	policy := &Policy{DefaultRoles: []string{"customer"}, Users: map[string][]string{"1": {"admin"}}}
+	tests := []struct {
+		name       string
+		userID     string
+		tokenRoles []string
+		want       []string
+	}{
+		{"mapped user", "1", nil, []string{"customer", "admin"}},
+		{"unmapped user", "2", nil, []string{"customer"}},
+		{"token without user id", "", nil, []string{"customer"}},
+		{"token roles", "2", []string{"support"}, []string{"customer", "support"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.UserRoles(tt.userID, tt.tokenRoles); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UserRoles(%q, %v) = %v, want %v", tt.userID, tt.tokenRoles, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadPolicyUsers(t *testing.T) {
+	tests := []struct {
+		name    string
+		users   string
+		wantErr bool
+	}{
+		{"user ids", `{"1": [admin], "42": [support]}`, false},
+		{"no users", `{}`, false},
+		{"username", `{admin: [admin]}`, true},
+		{"zero id", `{"0": [admin]}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "policy.yaml")
+			content := "users: " + tt.users + "\nrules:\n  - path: /admin*\n    roles: [admin]\n"
+			if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadPolicy(path); (err != nil) != tt.wantErr {
+				t.Errorf("LoadPolicy() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rate limit keys
+const (
+	RateLimitByIP     = "ip"
+	RateLimitByUser   = "user"
+	RateLimitByAPIKey = "api_key"
+)
+
+// DefaultRateLimits protect the login endpoint against credential stuffing and
+// the catalogue against scraping
+const DefaultRateLimits = "/auth/login=10/1m@ip,/auth/register=10/1m@ip,/product=300/1m@ip,/cart=120/1m@user"
+
+// RateLimit allows Requests per Period to every client of the routes starting with Route
+type RateLimit struct {
+	Route    string        `yaml:"route"`
+	Requests int           `yaml:"requests"`
+	Period   time.Duration `yaml:"period"`
+	// Key identifies clients: ip, user or api_key
+	Key string `yaml:"key"`
+}
+
+// ParseRateLimits parses a comma separated list of limits in the form
+// <route prefix>=<requests>/<period>[@<key>], e.g. /auth/login=10/1m@ip
+func ParseRateLimits(s string) ([]RateLimit, error) {
+	var limits []RateLimit
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		route, spec, ok := strings.Cut(item, "=")
+		if !ok || !strings.HasPrefix(route, "/") {
+			return nil, fmt.Errorf("rate limit %q: expected <route>=<requests>/<period>[@<key>]", item)
+		}
+
+		limit := RateLimit{Route: route, Key: RateLimitByIP}
+		if rate, key, ok := strings.Cut(spec, "@"); ok {
+			spec, limit.Key = rate, key
+		}
+		switch limit.Key {
+		case RateLimitByIP, RateLimitByUser, RateLimitByAPIKey:
+		default:
+			return nil, fmt.Errorf("rate limit %q: unknown key %q", item, limit.Key)
+		}
+
+		requests, period, ok := strings.Cut(spec, "/")
+		if !ok {
+			return nil, fmt.Errorf("rate limit %q: expected <requests>/<period>", item)
+		}
+
+		var err error
+		if limit.Requests, err = strconv.Atoi(requests); err != nil || limit.Requests <= 0 {
+			return nil, fmt.Errorf("rate limit %q: invalid number of requests %q", item, requests)
+		}
+		if limit.Period, err = time.ParseDuration(period); err != nil || limit.Period <= 0 {
+			return nil, fmt.Errorf("rate limit %q: invalid period %q", item, period)
+		}
+
+		limits = append(limits, limit)
+	}
+	return limits, nil
+}
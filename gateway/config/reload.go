@@ -0,0 +1,195 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// restartSettings are the sections that only take effect on a restart, Reload
+// keeps the active values of each of them
+var restartSettings = []string{"server.", "log.", "tracing.", "shutdown.", "reload.", "orders.", "payments."}
+
+// Snapshot is an applied configuration
+type Snapshot struct {
+	Config *Config
+	// Version counts the configurations applied since the gateway started
+	Version  int
+	Checksum string
+	LoadedAt time.Time
+}
+
+// ApplyFunc puts a configuration into effect. It returns an error when the
+// configuration cannot be used, the previous one then stays active.
+type ApplyFunc func(*Config) error
+
+// Reloader reloads the configuration from its sources and applies it when it
+// is valid and differs from the active one
+type Reloader struct {
+	args   []string
+	apply  ApplyFunc
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	current atomic.Pointer[Snapshot]
+}
+
+// NewReloader creates a reloader loading the configuration with the command
+// line arguments args
+func NewReloader(args []string, apply ApplyFunc, logger *slog.Logger) *Reloader {
+	return &Reloader{args: args, apply: apply, logger: logger}
+}
+
+// Init applies the initial configuration
+func (r *Reloader) Init(cfg *Config) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.apply(cfg); err != nil {
+		return err
+	}
+	r.current.Store(&Snapshot{Config: cfg, Version: 1, Checksum: cfg.Checksum(), LoadedAt: time.Now()})
+	return nil
+}
+
+// Current returns the active configuration
+func (r *Reloader) Current() *Snapshot {
+	return r.current.Load()
+}
+
+// Reload loads the configuration again and applies it. Invalid configurations
+// are rejected and logged together with their differences to the active one.
+func (r *Reloader) Reload() (*Snapshot, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current := r.current.Load()
+	cfg, err := load(r.args)
+	if err != nil {
+		r.logger.Error("Rejected configuration", "version", current.Version, "error", err)
+		return current, err
+	}
+
+	// Settings read once at startup keep their values until a restart
+	var restart []string
+	for _, change := range Diff(current.Config, cfg) {
+		for _, prefix := range restartSettings {
+			if strings.HasPrefix(change, prefix) {
+				restart = append(restart, change)
+			}
+		}
+	}
+	if len(restart) > 0 {
+		r.logger.Warn("Configuration changes require a restart and are ignored", "diff", restart)
+		cfg.Server, cfg.Log, cfg.Tracing, cfg.Shutdown, cfg.Reload, cfg.Orders, cfg.Payments =
+			current.Config.Server, current.Config.Log, current.Config.Tracing, current.Config.Shutdown, current.Config.Reload,
+			current.Config.Orders, current.Config.Payments
+	}
+
+	diff := Diff(current.Config, cfg)
+	if err := cfg.Validate(); err != nil {
+		err = fmt.Errorf("invalid configuration:\n%w", err)
+		r.logger.Error("Rejected configuration", "version", current.Version, "diff", diff, "error", err)
+		return current, err
+	}
+
+	checksum := cfg.Checksum()
+	if checksum == current.Checksum {
+		r.logger.Debug("Configuration unchanged", "version", current.Version)
+		return current, nil
+	}
+
+	if err := r.apply(cfg); err != nil {
+		r.logger.Error("Rejected configuration", "version", current.Version, "diff", diff, "error", err)
+		return current, err
+	}
+
+	next := &Snapshot{Config: cfg, Version: current.Version + 1, Checksum: checksum, LoadedAt: time.Now()}
+	r.current.Store(next)
+	r.logger.Info("Applied configuration", "version", next.Version, "checksum", checksum, "diff", diff)
+	return next, nil
+}
+
+// Watch reloads the configuration on SIGHUP and whenever the content of the
+// config file or the policy file changes until ctx is done. The files are
+// polled rather than watched for events so that replaced files and symlinks
+// (e.g. Kubernetes ConfigMaps) are noticed as well.
+func (r *Reloader) Watch(ctx context.Context) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	cfg := r.Current().Config
+	var ticks <-chan time.Time
+	if (cfg.Source != "" || cfg.PolicyFile != "") && cfg.Reload.Interval > 0 {
+		ticker := time.NewTicker(cfg.Reload.Interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+	last := r.watchedFiles()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			r.logger.Info("Reloading configuration on SIGHUP")
+			last = r.watchedFiles()
+			r.Reload()
+		case <-ticks:
+			sums := r.watchedFiles()
+			var changed []string
+			for path, sum := range sums {
+				if last[path] != sum {
+					changed = append(changed, path)
+				}
+			}
+			last = sums
+			if len(changed) == 0 {
+				continue
+			}
+			sort.Strings(changed)
+			r.logger.Info("Reloading configuration, files changed", "files", changed)
+			r.Reload()
+		}
+	}
+}
+
+// watchedFiles returns the checksums of the config file and the policy file
+// of the active configuration by path
+func (r *Reloader) watchedFiles() map[string]string {
+	cfg := r.Current().Config
+	sums := make(map[string]string, 2)
+	for _, path := range []string{cfg.Source, cfg.PolicyFile} {
+		if path != "" {
+			sums[path] = fileChecksum(path)
+		}
+	}
+	return sums
+}
+
+// fileChecksum returns the checksum of the file content or the error reading it
+func fileChecksum(path string) string {
+	if path == "" {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "missing"
+		}
+		return err.Error()
+	}
+	sum := sha256.Sum256(data)
+	return string(sum[:])
+}
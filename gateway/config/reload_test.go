@@ -0,0 +1,101 @@
+package config
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReloadKeepsRestartSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gateway.yaml")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("auth: {secret: test, upstream_secret: test}\nserver: {port: 8080}\norders: {store: memory}\npayments: {provider: fake, fake_outcome: succeed, currency: USD}\npagination: {default_limit: 20}\n")
+
+	args := []string{"-config", path}
+	cfg, err := Load(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloader := NewReloader(args, func(*Config) error { return nil }, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err := reloader.Init(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	write("auth: {secret: test, upstream_secret: test}\nserver: {port: 9090}\norders: {store: postgres, dsn: postgres://orders}\npayments: {provider: fake, fake_outcome: succeed, currency: EUR}\npagination: {default_limit: 50}\n")
+	snapshot, err := reloader.Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	got := snapshot.Config
+	tests := []struct {
+		setting   string
+		got, want interface{}
+	}{
+		{"server.port", got.Server.Port, 8080},
+		{"orders.store", got.Orders.Store, OrdersMemory},
+		{"orders.dsn", got.Orders.DSN, ""},
+		{"payments.currency", got.Payments.Currency, "USD"},
+		{"pagination.default_limit", got.Pagination.DefaultLimit, 50},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.setting, tt.got, tt.want)
+		}
+	}
+}
+
+func TestReloadPolicyFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "gateway.yaml")
+	policy := filepath.Join(dir, "policy.yaml")
+	rules := func(role string) {
+		t.Helper()
+		content := "rules:\n  - path: /admin*\n    roles: [" + role + "]\n"
+		if err := os.WriteFile(policy, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(path, []byte("auth: {secret: test, upstream_secret: test}\npayments: {provider: fake, fake_outcome: succeed}\npolicy_file: "+policy+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	rules("admin")
+
+	args := []string{"-config", path}
+	cfg, err := Load(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var applied int
+	reloader := NewReloader(args, func(*Config) error { applied++; return nil }, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err := reloader.Init(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name        string
+		role        string
+		wantVersion int
+	}{
+		{"unchanged policy", "admin", 1},
+		{"changed policy", "operator", 2},
+		{"unchanged again", "operator", 2},
+	}
+	for _, step := range steps {
+		rules(step.role)
+		snapshot, err := reloader.Reload()
+		if err != nil {
+			t.Fatalf("%s: Reload() error = %v", step.name, err)
+		}
+		if snapshot.Version != step.wantVersion || applied != step.wantVersion {
+			t.Errorf("%s: version = %d, applied %d times, want %d", step.name, snapshot.Version, applied, step.wantVersion)
+		}
+	}
+}
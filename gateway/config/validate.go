@@ -0,0 +1,271 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RequiredUpstreams are the upstreams the built-in handlers proxy to
+var RequiredUpstreams = []string{"auth", "product", "cart"}
+
+// Validate reports every invalid setting of the configuration at once
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	positive := func(d time.Duration, name string) {
+		check(d > 0, "%s must be positive, got %s", name, d)
+	}
+
+	check(c.Server.Port > 0 && c.Server.Port <= 65535, "server.port must be between 1 and 65535, got %d", c.Server.Port)
+	positive(c.Server.ReadHeaderTimeout, "server.read_header_timeout")
+	positive(c.Server.ReadTimeout, "server.read_timeout")
+	positive(c.Server.WriteTimeout, "server.write_timeout")
+	positive(c.Server.IdleTimeout, "server.idle_timeout")
+
+	for _, name := range RequiredUpstreams {
+		check(c.Upstreams[name] != nil, "upstreams.%s is required", name)
+	}
+	names := make([]string, 0, len(c.Upstreams))
+	for name := range c.Upstreams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		upstream := c.Upstreams[name]
+		if upstream == nil {
+			check(false, "upstreams.%s is empty", name)
+			continue
+		}
+		d := upstream.Discovery
+		switch d.Provider {
+		case DiscoveryStatic:
+			if len(upstream.Instances) == 0 {
+				check(validURL(upstream.URL), "upstreams.%s.url must be an absolute http(s) URL, got %q", name, upstream.URL)
+			}
+			for i, instance := range upstream.Instances {
+				check(validURL(instance.URL), "upstreams.%s.instances[%d].url must be an absolute http(s) URL, got %q", name, i, instance.URL)
+				check(instance.Weight > 0, "upstreams.%s.instances[%d].weight must be positive, got %d", name, i, instance.Weight)
+			}
+		case DiscoveryDNS:
+			check(d.DNS.Name != "", "upstreams.%s.discovery.dns.name is required", name)
+			switch d.DNS.Record {
+			case RecordSRV:
+			case RecordA:
+				check(d.DNS.Port > 0 && d.DNS.Port <= 65535, "upstreams.%s.discovery.dns.port must be between 1 and 65535 for a records, got %d", name, d.DNS.Port)
+			default:
+				check(false, "upstreams.%s.discovery.dns.record must be srv or a, got %q", name, d.DNS.Record)
+			}
+			check(d.DNS.Scheme == "http" || d.DNS.Scheme == "https", "upstreams.%s.discovery.dns.scheme must be http or https, got %q", name, d.DNS.Scheme)
+			positive(d.DNS.MinRefresh, "upstreams."+name+".discovery.dns.min_refresh")
+			check(d.DNS.MaxRefresh >= d.DNS.MinRefresh, "upstreams.%s.discovery.dns.max_refresh must not be less than min_refresh", name)
+		case DiscoveryFile:
+			check(d.File.Path != "", "upstreams.%s.discovery.file.path is required", name)
+			positive(d.File.Interval, "upstreams."+name+".discovery.file.interval")
+		default:
+			check(false, "upstreams.%s.discovery.provider must be static, dns or file, got %q", name, d.Provider)
+		}
+		positive(d.RetryInterval, "upstreams."+name+".discovery.retry_interval")
+		switch upstream.Balancer {
+		case BalancerRoundRobin, BalancerLeastConnections, BalancerWeighted, BalancerConsistentHash:
+		default:
+			check(false, "upstreams.%s.balancer must be round_robin, least_connections, weighted or consistent_hash, got %q", name, upstream.Balancer)
+		}
+
+		h := upstream.HealthCheck
+		check(h.Interval >= 0, "upstreams.%s.health_check.interval must not be negative", name)
+		positive(h.Timeout, "upstreams."+name+".health_check.timeout")
+		check(h.UnhealthyThreshold > 0 && h.HealthyThreshold > 0, "upstreams.%s.health_check thresholds must be positive", name)
+		check(h.Interval == 0 || upstream.HealthPath != "", "upstreams.%s.health_check requires health_path", name)
+
+		o := upstream.OutlierDetection
+		check(o.Consecutive5xx >= 0, "upstreams.%s.outlier_detection.consecutive_5xx must not be negative", name)
+		positive(o.EjectionTime, "upstreams."+name+".outlier_detection.ejection_time")
+		check(o.MaxEjectionPercent > 0 && o.MaxEjectionPercent <= 100,
+			"upstreams.%s.outlier_detection.max_ejection_percent must be between 1 and 100, got %d", name, o.MaxEjectionPercent)
+		check(upstream.HealthPath == "" || strings.HasPrefix(upstream.HealthPath, "/"),
+			"upstreams.%s.health_path must start with /, got %q", name, upstream.HealthPath)
+
+		t := upstream.Transport
+		prefix := "upstreams." + name + ".transport."
+		positive(t.DialTimeout, prefix+"dial_timeout")
+		positive(t.TLSHandshakeTimeout, prefix+"tls_handshake_timeout")
+		positive(t.ResponseHeaderTimeout, prefix+"response_header_timeout")
+		positive(t.Timeout, prefix+"timeout")
+		positive(t.IdleConnTimeout, prefix+"idle_conn_timeout")
+		check(t.MaxIdleConnsPerHost > 0, "%smax_idle_conns_per_host must be positive, got %d", prefix, t.MaxIdleConnsPerHost)
+	}
+
+	for i, route := range c.Routes {
+		check(validMethod(route.Method), "routes[%d].method %q is not an HTTP method", i, route.Method)
+		check(strings.HasPrefix(route.Path, "/"), "routes[%d].path must start with /, got %q", i, route.Path)
+		check(route.Target == "" || strings.HasPrefix(route.Target, "/"), "routes[%d].target must start with /, got %q", i, route.Target)
+		check(c.Upstreams[route.Upstream] != nil, "routes[%d].upstream %q is not configured", i, route.Upstream)
+		check(!route.Coalesce || strings.EqualFold(route.Method, http.MethodGet), "routes[%d].coalesce requires method GET, got %q", i, route.Method)
+	}
+
+	switch {
+	case c.Auth.Algorithm == "HS256", c.Auth.Algorithm == "HS384", c.Auth.Algorithm == "HS512":
+		check(c.Auth.Secret != "", "auth.secret is required for %s", c.Auth.Algorithm)
+	case validAsymmetricAlgorithm(c.Auth.Algorithm):
+		check(c.Auth.PublicKeyFile != "", "auth.public_key_file is required for %s", c.Auth.Algorithm)
+	default:
+		check(false, "auth.algorithm %q is not supported", c.Auth.Algorithm)
+	}
+	check(c.Auth.UpstreamSecret != "", "auth.upstream_secret is required, the upstreams trust the identity headers only with it")
+
+	for i, limit := range c.RateLimits {
+		check(strings.HasPrefix(limit.Route, "/"), "rate_limits[%d].route must start with /, got %q", i, limit.Route)
+		check(limit.Requests > 0, "rate_limits[%d].requests must be positive, got %d", i, limit.Requests)
+		positive(limit.Period, fmt.Sprintf("rate_limits[%d].period", i))
+		switch limit.Key {
+		case RateLimitByIP, RateLimitByUser, RateLimitByAPIKey:
+		default:
+			check(false, "rate_limits[%d].key must be ip, user or api_key, got %q", i, limit.Key)
+		}
+	}
+
+	for _, proxy := range c.TrustedProxies {
+		_, _, err := net.ParseCIDR(proxy)
+		check(err == nil || net.ParseIP(proxy) != nil, "trusted_proxies: %q is neither an IP nor a CIDR", proxy)
+	}
+
+	errs = append(errs, c.CORS.validate("cors")...)
+	for i, override := range c.CORS.Overrides {
+		name := fmt.Sprintf("cors.overrides[%d]", i)
+		check(strings.HasPrefix(override.Path, "/"), "%s.path must start with /, got %q", name, override.Path)
+		errs = append(errs, c.CORS.Override(override).validate(name)...)
+	}
+
+	if c.Cache.Enabled {
+		positive(c.Cache.TTL, "cache.ttl")
+		check(c.Cache.MaxEntries > 0, "cache.max_entries must be positive, got %d", c.Cache.MaxEntries)
+	}
+
+	for i, route := range c.Coalescing.Routes {
+		check(strings.HasPrefix(route, "/"), "coalescing.routes[%d] must start with /, got %q", i, route)
+	}
+
+	if c.Idempotency.Enabled {
+		positive(c.Idempotency.TTL, "idempotency.ttl")
+		check(c.Idempotency.MaxEntries > 0, "idempotency.max_entries must be positive, got %d", c.Idempotency.MaxEntries)
+		check(c.Idempotency.MaxBodyBytes > 0, "idempotency.max_body_bytes must be positive, got %d", c.Idempotency.MaxBodyBytes)
+	}
+
+	// A page reads a window of twice its size from the product service, which
+	// serves at most 500 products per call
+	check(c.Pagination.MaxLimit > 0 && c.Pagination.MaxLimit <= 200, "pagination.max_limit must be between 1 and 200, got %d", c.Pagination.MaxLimit)
+	check(c.Pagination.DefaultLimit > 0 && c.Pagination.DefaultLimit <= c.Pagination.MaxLimit,
+		"pagination.default_limit must be between 1 and pagination.max_limit, got %d", c.Pagination.DefaultLimit)
+
+	check(c.Search.Backend == SearchMemory, "search.backend must be memory, got %q", c.Search.Backend)
+	positive(c.Search.RefreshInterval, "search.refresh_interval")
+	check(c.Search.MaxLimit > 0, "search.max_limit must be positive, got %d", c.Search.MaxLimit)
+	check(c.Search.DefaultLimit > 0 && c.Search.DefaultLimit <= c.Search.MaxLimit,
+		"search.default_limit must be between 1 and search.max_limit, got %d", c.Search.DefaultLimit)
+
+	check(c.Cart.MaxQuantity > 0, "cart.max_quantity must be positive, got %d", c.Cart.MaxQuantity)
+
+	switch c.Orders.Store {
+	case OrdersMemory:
+	case OrdersPostgres:
+		check(c.Orders.DSN != "", "orders.dsn is required for the postgres store")
+	default:
+		check(false, "orders.store must be memory or postgres, got %q", c.Orders.Store)
+	}
+
+	// The fake provider charges nothing, it has to be chosen explicitly
+	switch c.Payments.Provider {
+	case "":
+		check(false, "payments.provider is required, fake takes orders without charging them")
+	case PaymentsFake:
+		switch c.Payments.FakeOutcome {
+		case "succeed", "decline", "timeout":
+		case "":
+			check(false, "payments.fake_outcome is required for the fake provider")
+		default:
+			check(false, "payments.fake_outcome must be succeed, decline or timeout, got %q", c.Payments.FakeOutcome)
+		}
+	default:
+		check(false, "payments.provider must be fake, got %q", c.Payments.Provider)
+	}
+	check(validCurrency(c.Payments.Currency), "payments.currency must be an ISO 4217 code such as USD, got %q", c.Payments.Currency)
+	positive(c.Payments.Timeout, "payments.timeout")
+
+	check(c.Breaker.FailureThreshold > 0, "breaker.failure_threshold must be positive, got %d", c.Breaker.FailureThreshold)
+	positive(c.Breaker.OpenTimeout, "breaker.open_timeout")
+	check(c.Retry.Attempts > 0, "retry.attempts must be positive, got %d", c.Retry.Attempts)
+	positive(c.Retry.BaseDelay, "retry.base_delay")
+	check(c.Retry.MaxDelay >= c.Retry.BaseDelay, "retry.max_delay must not be less than retry.base_delay")
+
+	positive(c.Readiness.Timeout, "readiness.timeout")
+	check(c.Readiness.CacheTTL >= 0, "readiness.cache_ttl must not be negative")
+
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		check(false, "log.level must be debug, info, warn or error, got %q", c.Log.Level)
+	}
+	switch strings.ToLower(c.Log.Format) {
+	case "json", "text":
+	default:
+		check(false, "log.format must be json or text, got %q", c.Log.Format)
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		check(false, "tracing.exporter must be none, stdout or otlp, got %q", c.Tracing.Exporter)
+	}
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio must be between 0 and 1, got %g", c.Tracing.SampleRatio)
+
+	check(c.Shutdown.Delay >= 0, "shutdown.delay must not be negative")
+	positive(c.Shutdown.GracePeriod, "shutdown.grace_period")
+	check(c.Reload.Interval >= 0, "reload.interval must not be negative")
+
+	return errors.Join(errs...)
+}
+
+func validURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func validMethod(method string) bool {
+	switch strings.ToUpper(method) {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+func validAsymmetricAlgorithm(algorithm string) bool {
+	switch algorithm {
+	case "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA":
+		return true
+	}
+	return false
+}
+
+// validCurrency reports whether code has the form of an ISO 4217 code
+func validCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
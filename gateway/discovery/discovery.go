@@ -0,0 +1,136 @@
+// Package discovery resolves the instances of upstream services from the
+// static configuration, DNS records or a registry file and keeps them up to
+// date.
+package discovery
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"gateway/transport"
+)
+
+// resolveTimeout limits a single resolution
+const resolveTimeout = 5 * time.Second
+
+// Provider resolves the instances of an upstream
+type Provider interface {
+	// Resolve returns the current instances and how long they stay valid,
+	// 0 means they never change
+	Resolve(ctx context.Context) ([]transport.Endpoint, time.Duration, error)
+}
+
+// Static is a fixed set of instances
+type Static []transport.Endpoint
+
+// Resolve returns the instances
+func (s Static) Resolve(context.Context) ([]transport.Endpoint, time.Duration, error) {
+	return s, 0, nil
+}
+
+// Watcher keeps the instances of an upstream up to date. A failed resolution
+// or an empty result keeps the known instances and is retried.
+type Watcher struct {
+	name     string
+	provider Provider
+	retry    time.Duration
+	update   func([]transport.Endpoint)
+
+	current []transport.Endpoint
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewWatcher creates a watcher passing every changed set of instances of
+// the upstream name to update
+func NewWatcher(name string, provider Provider, retry time.Duration, update func([]transport.Endpoint)) *Watcher {
+	return &Watcher{
+		name:     name,
+		provider: provider,
+		retry:    retry,
+		update:   update,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start resolves the instances once and refreshes them in the background
+// until Stop is called
+func (w *Watcher) Start() {
+	ttl := w.refresh()
+	if ttl <= 0 {
+		close(w.done)
+		return
+	}
+
+	go func() {
+		defer close(w.done)
+		timer := time.NewTimer(ttl)
+		defer timer.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-timer.C:
+				if ttl = w.refresh(); ttl <= 0 {
+					return
+				}
+				timer.Reset(ttl)
+			}
+		}
+	}()
+}
+
+// Stop ends the background refresh
+func (w *Watcher) Stop() {
+	select {
+	case <-w.stop:
+	default:
+		close(w.stop)
+	}
+	<-w.done
+}
+
+// refresh resolves the instances and returns when to do it again
+func (w *Watcher) refresh() time.Duration {
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	endpoints, ttl, err := w.provider.Resolve(ctx)
+
+	switch {
+	case err != nil:
+		log.Printf("Failed to discover the instances of %s upstream, keeping %d known: %v", w.name, len(w.current), err)
+		return w.retry
+	case len(endpoints) == 0:
+		log.Printf("Discovered no instances of %s upstream, keeping %d known", w.name, len(w.current))
+		return w.retry
+	}
+
+	endpoints = sorted(endpoints)
+	if !equal(w.current, endpoints) {
+		log.Printf("Discovered %d instances of %s upstream", len(endpoints), w.name)
+		w.current = endpoints
+		w.update(endpoints)
+	}
+	return ttl
+}
+
+func sorted(endpoints []transport.Endpoint) []transport.Endpoint {
+	endpoints = append([]transport.Endpoint(nil), endpoints...)
+	sort.Slice(endpoints, func(i, j int) bool { return endpoints[i].URL < endpoints[j].URL })
+	return endpoints
+}
+
+func equal(a, b []transport.Endpoint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
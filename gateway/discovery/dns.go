@@ -0,0 +1,273 @@
+package discovery
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gateway/transport"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// DNS record types
+const (
+	RecordSRV = "srv"
+	// RecordA resolves both A and AAAA records
+	RecordA = "a"
+)
+
+// defaultNameserver is used when resolv.conf names none
+const defaultNameserver = "127.0.0.1:53"
+
+// DNSConfig configures resolution of the instances from DNS records
+type DNSConfig struct {
+	// Name is the domain name to look up
+	Name   string
+	Record string
+	// Scheme of the instance URLs
+	Scheme string
+	// Port of the instances for A records, SRV records carry their own
+	Port int
+	// Server is the nameserver address, the first one of /etc/resolv.conf by default
+	Server string
+	// MinRefresh and MaxRefresh bound the record TTLs
+	MinRefresh time.Duration
+	MaxRefresh time.Duration
+}
+
+// DNS resolves the instances from SRV or A/AAAA records and refreshes them
+// when the records expire
+type DNS struct {
+	config DNSConfig
+	name   dnsmessage.Name
+}
+
+// NewDNS creates a DNS provider
+func NewDNS(config DNSConfig) (*DNS, error) {
+	name, err := dnsmessage.NewName(fqdn(config.Name))
+	if err != nil {
+		return nil, fmt.Errorf("dns name %q: %w", config.Name, err)
+	}
+	if config.Server == "" {
+		config.Server = systemNameserver()
+	} else if _, _, err := net.SplitHostPort(config.Server); err != nil {
+		config.Server = net.JoinHostPort(config.Server, "53")
+	}
+	return &DNS{config: config, name: name}, nil
+}
+
+// Resolve looks the records up and returns the instances until the shortest
+// TTL of the answers expires
+func (d *DNS) Resolve(ctx context.Context) ([]transport.Endpoint, time.Duration, error) {
+	var endpoints []transport.Endpoint
+	var ttl uint32
+	var err error
+	if d.config.Record == RecordSRV {
+		endpoints, ttl, err = d.resolveSRV(ctx)
+	} else {
+		endpoints, ttl, err = d.resolveA(ctx)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	refresh := time.Duration(ttl) * time.Second
+	if refresh < d.config.MinRefresh {
+		refresh = d.config.MinRefresh
+	}
+	if d.config.MaxRefresh > 0 && refresh > d.config.MaxRefresh {
+		refresh = d.config.MaxRefresh
+	}
+	return endpoints, refresh, nil
+}
+
+// resolveSRV returns the targets of the SRV records with the lowest priority
+func (d *DNS) resolveSRV(ctx context.Context) ([]transport.Endpoint, uint32, error) {
+	answers, err := d.query(ctx, dnsmessage.TypeSRV)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var records []*dnsmessage.SRVResource
+	ttl := uint32(0)
+	for _, answer := range answers {
+		record, ok := answer.Body.(*dnsmessage.SRVResource)
+		if !ok {
+			continue
+		}
+		records = append(records, record)
+		ttl = minTTL(ttl, answer.Header.TTL)
+	}
+	if len(records) == 0 {
+		return nil, ttl, nil
+	}
+
+	sort.Slice(records, func(i, j int) bool { return records[i].Priority < records[j].Priority })
+	var endpoints []transport.Endpoint
+	for _, record := range records {
+		if record.Priority != records[0].Priority {
+			break
+		}
+		weight := int(record.Weight)
+		if weight == 0 {
+			weight = 1
+		}
+		host := strings.TrimSuffix(record.Target.String(), ".")
+		endpoints = append(endpoints, transport.Endpoint{URL: d.url(host, int(record.Port)), Weight: weight})
+	}
+	return endpoints, ttl, nil
+}
+
+// resolveA returns the addresses of the A and AAAA records
+func (d *DNS) resolveA(ctx context.Context) ([]transport.Endpoint, uint32, error) {
+	var endpoints []transport.Endpoint
+	ttl := uint32(0)
+	for _, recordType := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		answers, err := d.query(ctx, recordType)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, answer := range answers {
+			var ip net.IP
+			switch record := answer.Body.(type) {
+			case *dnsmessage.AResource:
+				ip = record.A[:]
+			case *dnsmessage.AAAAResource:
+				ip = record.AAAA[:]
+			default:
+				continue
+			}
+			endpoints = append(endpoints, transport.Endpoint{URL: d.url(ip.String(), d.config.Port), Weight: 1})
+			ttl = minTTL(ttl, answer.Header.TTL)
+		}
+	}
+	return endpoints, ttl, nil
+}
+
+func (d *DNS) url(host string, port int) string {
+	return d.config.Scheme + "://" + net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// query asks the nameserver over UDP and repeats truncated answers over TCP
+func (d *DNS) query(ctx context.Context, recordType dnsmessage.Type) ([]dnsmessage.Resource, error) {
+	id := uint16(rand.Intn(1 << 16))
+	request := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{
+			{Name: d.name, Type: recordType, Class: dnsmessage.ClassINET},
+		},
+	}
+	packet, err := request.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := d.exchange(ctx, "udp", packet, id)
+	if err == nil && response.Truncated {
+		response, err = d.exchange(ctx, "tcp", packet, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("lookup %s: %w", d.config.Name, err)
+	}
+
+	switch response.RCode {
+	case dnsmessage.RCodeSuccess:
+		return response.Answers, nil
+	case dnsmessage.RCodeNameError:
+		return nil, fmt.Errorf("lookup %s: no such host", d.config.Name)
+	default:
+		return nil, fmt.Errorf("lookup %s: server answered %s", d.config.Name, response.RCode)
+	}
+}
+
+func (d *DNS) exchange(ctx context.Context, network string, packet []byte, id uint16) (*dnsmessage.Message, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, d.config.Server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	var answer []byte
+	if network == "tcp" {
+		// Messages over TCP are prefixed with their length
+		framed := binary.BigEndian.AppendUint16(nil, uint16(len(packet)))
+		if _, err := conn.Write(append(framed, packet...)); err != nil {
+			return nil, err
+		}
+		var length [2]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return nil, err
+		}
+		answer = make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(conn, answer); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := conn.Write(packet); err != nil {
+			return nil, err
+		}
+		answer = make([]byte, 65535)
+		n, err := conn.Read(answer)
+		if err != nil {
+			return nil, err
+		}
+		answer = answer[:n]
+	}
+
+	var response dnsmessage.Message
+	if err := response.Unpack(answer); err != nil {
+		return nil, err
+	}
+	if response.ID != id || !response.Response {
+		return nil, errors.New("unexpected answer")
+	}
+	return &response, nil
+}
+
+// systemNameserver returns the first nameserver of /etc/resolv.conf
+func systemNameserver() string {
+	file, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return defaultNameserver
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			return net.JoinHostPort(fields[1], "53")
+		}
+	}
+	return defaultNameserver
+}
+
+// fqdn returns name fully qualified
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+func minTTL(current, ttl uint32) uint32 {
+	if current == 0 || ttl < current {
+		return ttl
+	}
+	return current
+}
@@ -0,0 +1,71 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"gateway/transport"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File resolves the instances of an upstream from a registry file that maps
+// upstream names to their instances, in YAML or JSON:
+//
+//	product:
+//	  - url: http://product-1:8001
+//	  - url: http://product-2:8001
+//	    weight: 2
+//
+// The file is read again every interval so that edits take effect without a
+// restart.
+type File struct {
+	path     string
+	upstream string
+	interval time.Duration
+}
+
+type registryInstance struct {
+	URL    string `yaml:"url"`
+	Weight int    `yaml:"weight"`
+}
+
+// NewFile creates a provider of the instances of upstream listed in the
+// registry file at path
+func NewFile(path, upstream string, interval time.Duration) *File {
+	return &File{path: path, upstream: upstream, interval: interval}
+}
+
+// Resolve reads the registry file
+func (f *File) Resolve(context.Context) ([]transport.Endpoint, time.Duration, error) {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("read registry: %w", err)
+	}
+
+	// JSON is valid YAML
+	var registry map[string][]registryInstance
+	if err := yaml.Unmarshal(data, &registry); err != nil {
+		return nil, 0, fmt.Errorf("parse registry %s: %w", f.path, err)
+	}
+
+	var endpoints []transport.Endpoint
+	for _, instance := range registry[f.upstream] {
+		u, err := url.Parse(instance.URL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, 0, fmt.Errorf("registry %s: %s: %q is not a valid URL", f.path, f.upstream, instance.URL)
+		}
+		if instance.Weight < 0 {
+			return nil, 0, fmt.Errorf("registry %s: %s: weight of %s must not be negative", f.path, f.upstream, instance.URL)
+		}
+		weight := instance.Weight
+		if weight == 0 {
+			weight = 1
+		}
+		endpoints = append(endpoints, transport.Endpoint{URL: instance.URL, Weight: weight})
+	}
+	return endpoints, f.interval, nil
+}
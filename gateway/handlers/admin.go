@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"gateway/config"
+	"gateway/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler exposes the configuration of the gateway to operators
+type AdminHandler struct {
+	reloader *config.Reloader
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(reloader *config.Reloader) *AdminHandler {
+	return &AdminHandler{reloader: reloader}
+}
+
+// Config godoc
+// @Summary Get active configuration
+// @Description Get the version and settings of the active gateway configuration, secrets are redacted
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} models.ConfigVersion
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/config [get]
+func (h *AdminHandler) Config(c *gin.Context) {
+	c.JSON(http.StatusOK, configVersion(h.reloader.Current()))
+}
+
+// Reload godoc
+// @Summary Reload configuration
+// @Description Reload the gateway configuration from its sources, invalid configurations are rejected
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} models.ConfigVersion
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/config/reload [post]
+func (h *AdminHandler) Reload(c *gin.Context) {
+	snapshot, err := h.reloader.Reload()
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, configVersion(snapshot))
+}
+
+func configVersion(snapshot *config.Snapshot) models.ConfigVersion {
+	return models.ConfigVersion{
+		Version:  snapshot.Version,
+		Checksum: snapshot.Checksum,
+		LoadedAt: snapshot.LoadedAt,
+		Source:   snapshot.Config.Source,
+		Config:   snapshot.Config.Document(),
+	}
+}
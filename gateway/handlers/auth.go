@@ -1,10 +1,7 @@
 package handlers
 
 import (
-	"bytes"
-	"encoding/json"
 	"gateway/models"
-	"io"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -12,16 +9,12 @@ import (
 
 // AuthHandler handles authentication related requests
 type AuthHandler struct {
-	serviceURL string
-	client     *http.Client
+	upstream *Upstream
 }
 
 // NewAuthHandler creates a new auth handler
-func NewAuthHandler(serviceURL string) *AuthHandler {
-	return &AuthHandler{
-		serviceURL: serviceURL,
-		client:     &http.Client{},
-	}
+func NewAuthHandler(upstream *Upstream) *AuthHandler {
+	return &AuthHandler{upstream: upstream}
 }
 
 // Register godoc
@@ -41,38 +34,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	jsonData, err := json.Marshal(userCreate)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to marshal request"})
-		return
-	}
-
-	req, err := http.NewRequest("POST", h.serviceURL+"/auth/register", bytes.NewBuffer(jsonData))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to create request"})
-		return
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	copyHeaders(c.Request, req)
-
-	resp, err := h.client.Do(req)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to communicate with auth service"})
-		return
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to read response"})
-		return
-	}
-
-	// Copy cookies from the response
-	copyCookies(resp, c)
-
-	c.Data(resp.StatusCode, "application/json", body)
+	h.upstream.ForwardJSON(c, "/auth/register", userCreate)
 }
 
 // Login godoc
@@ -92,38 +54,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	jsonData, err := json.Marshal(userLogin)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to marshal request"})
-		return
-	}
-
-	req, err := http.NewRequest("POST", h.serviceURL+"/auth/login", bytes.NewBuffer(jsonData))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to create request"})
-		return
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	copyHeaders(c.Request, req)
-
-	resp, err := h.client.Do(req)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to communicate with auth service"})
-		return
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to read response"})
-		return
-	}
-
-	// Copy cookies from the response
-	copyCookies(resp, c)
-
-	c.Data(resp.StatusCode, "application/json", body)
+	h.upstream.ForwardJSON(c, "/auth/login", userLogin)
 }
 
 // Logout godoc
@@ -134,31 +65,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 // @Success 200 {object} models.MessageResponse
 // @Router /auth/logout [post]
 func (h *AuthHandler) Logout(c *gin.Context) {
-	req, err := http.NewRequest("POST", h.serviceURL+"/auth/logout", nil)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to create request"})
-		return
-	}
-
-	copyHeaders(c.Request, req)
-
-	resp, err := h.client.Do(req)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to communicate with auth service"})
-		return
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to read response"})
-		return
-	}
-
-	// Copy cookies from the response
-	copyCookies(resp, c)
-
-	c.Data(resp.StatusCode, "application/json", body)
+	h.upstream.Forward(c, "/auth/logout")
 }
 
 // Info godoc
@@ -171,27 +78,5 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 // @Security BearerAuth
 // @Router /auth/info [get]
 func (h *AuthHandler) Info(c *gin.Context) {
-	req, err := http.NewRequest("GET", h.serviceURL+"/auth/info", nil)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to create request"})
-		return
-	}
-
-	copyHeaders(c.Request, req)
-
-	resp, err := h.client.Do(req)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to communicate with auth service"})
-		return
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to read response"})
-		return
-	}
-
-	c.Data(resp.StatusCode, "application/json", body)
+	h.upstream.Forward(c, "/auth/info")
 }
-
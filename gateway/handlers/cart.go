@@ -1,27 +1,27 @@
 package handlers
 
 import (
-	"bytes"
 	"encoding/json"
+	"fmt"
 	"gateway/models"
-	"io"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
 // CartHandler handles cart related requests
 type CartHandler struct {
-	serviceURL string
-	client     *http.Client
+	upstream    *Upstream
+	products    *Upstream
+	maxQuantity int
 }
 
-// NewCartHandler creates a new cart handler
-func NewCartHandler(serviceURL string) *CartHandler {
-	return &CartHandler{
-		serviceURL: serviceURL,
-		client:     &http.Client{},
-	}
+// NewCartHandler creates a new cart handler that checks and looks up the
+// products of cart items in the products upstream and accepts quantities up
+// to maxQuantity
+func NewCartHandler(upstream, products *Upstream, maxQuantity int) *CartHandler {
+	return &CartHandler{upstream: upstream, products: products, maxQuantity: maxQuantity}
 }
 
 // Get godoc
@@ -34,78 +34,77 @@ func NewCartHandler(serviceURL string) *CartHandler {
 // @Security BearerAuth
 // @Router /cart [get]
 func (h *CartHandler) Get(c *gin.Context) {
-	req, err := http.NewRequest("GET", h.serviceURL+"/cart", nil)
+	h.upstream.Forward(c, "/cart")
+}
+
+// Detailed godoc
+// @Summary Get cart items with product details
+// @Description Get the items in the current user's cart with the name, photo and price of their products, line totals and the subtotal. Items whose product is missing or cannot be looked up are returned with status not_found or unavailable and do not count towards the subtotal.
+// @Tags Cart
+// @Produce json
+// @Success 200 {object} models.DetailedCart
+// @Failure 401 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /cart/detailed [get]
+func (h *CartHandler) Detailed(c *gin.Context) {
+	status, body, err := h.upstream.fetch(c, http.MethodGet, "/cart")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to create request"})
+		h.upstream.abort(c, err)
 		return
 	}
-
-	copyHeaders(c.Request, req)
-
-	resp, err := h.client.Do(req)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to communicate with cart service"})
+	if status != http.StatusOK {
+		c.Data(status, "application/json", body)
 		return
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to read response"})
+	var items []models.CartItem
+	if err := json.Unmarshal(body, &items); err != nil {
+		c.JSON(http.StatusBadGateway, models.ErrorResponse{Error: "Invalid response from cart service"})
 		return
 	}
 
-	c.Data(resp.StatusCode, "application/json", body)
+	products := lookupProducts(c, h.products, items)
+	cart := models.DetailedCart{Items: make([]models.CartLine, 0, len(items)), Complete: true}
+	for _, item := range items {
+		line := models.CartLine{ID: item.ID, ProductID: item.ProductID, Quantity: item.Quantity}
+		result := products[item.ProductID]
+		line.Status = result.status
+		if result.product != nil {
+			line.Name = result.product.Name
+			line.Photo = result.product.Photo
+			line.UnitPrice = result.product.Price
+			line.LineTotal = roundPrice(result.product.Price * float64(item.Quantity))
+			cart.Subtotal += line.LineTotal
+		} else {
+			cart.Complete = false
+		}
+		cart.Items = append(cart.Items, line)
+	}
+	cart.Subtotal = roundPrice(cart.Subtotal)
+
+	c.JSON(http.StatusOK, cart)
 }
 
 // Add godoc
 // @Summary Add item to cart
-// @Description Add a new item to the current user's cart
+// @Description Add a new item to the current user's cart. The product must exist and the quantity must be between 1 and the configured maximum.
 // @Tags Cart
 // @Accept json
 // @Produce json
 // @Param item body models.CartItemCreate true "Cart item data"
 // @Success 201 {object} models.CartItem
-// @Failure 400 {object} models.ErrorResponse
+// @Failure 400 {object} models.ValidationErrorResponse
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
 // @Security BearerAuth
 // @Router /cart/add [post]
 func (h *CartHandler) Add(c *gin.Context) {
-	var cartItemCreate models.CartItemCreate
-	if err := c.ShouldBindJSON(&cartItemCreate); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
-		return
-	}
-
-	jsonData, err := json.Marshal(cartItemCreate)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to marshal request"})
-		return
-	}
-
-	req, err := http.NewRequest("POST", h.serviceURL+"/cart/add", bytes.NewBuffer(jsonData))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to create request"})
-		return
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	copyHeaders(c.Request, req)
-
-	resp, err := h.client.Do(req)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to communicate with cart service"})
-		return
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to read response"})
+	cartItemCreate, ok := h.bindItem(c)
+	if !ok {
 		return
 	}
 
-	c.Data(resp.StatusCode, "application/json", body)
+	h.upstream.ForwardJSON(c, "/cart/add", cartItemCreate)
 }
 
 // Update godoc
@@ -117,48 +116,58 @@ func (h *CartHandler) Add(c *gin.Context) {
 // @Param item_id path int true "Cart item ID"
 // @Param item body models.CartItemCreate true "Updated cart item data"
 // @Success 200 {object} models.CartItem
-// @Failure 400 {object} models.ErrorResponse
+// @Failure 400 {object} models.ValidationErrorResponse
 // @Failure 401 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
 // @Security BearerAuth
 // @Router /cart/update/{item_id} [put]
 func (h *CartHandler) Update(c *gin.Context) {
 	itemID := c.Param("item_id")
-	var cartItemCreate models.CartItemCreate
-	if err := c.ShouldBindJSON(&cartItemCreate); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
-		return
-	}
-
-	jsonData, err := json.Marshal(cartItemCreate)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to marshal request"})
-		return
-	}
-
-	req, err := http.NewRequest("PUT", h.serviceURL+"/cart/update/"+itemID, bytes.NewBuffer(jsonData))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to create request"})
-		return
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	copyHeaders(c.Request, req)
-
-	resp, err := h.client.Do(req)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to communicate with cart service"})
+	cartItemCreate, ok := h.bindItem(c)
+	if !ok {
 		return
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to read response"})
-		return
-	}
+	h.upstream.ForwardJSON(c, "/cart/update/"+itemID, cartItemCreate)
+}
 
-	c.Data(resp.StatusCode, "application/json", body)
+// bindItem decodes the cart item of the request body and checks that its
+// product exists and its quantity is within bounds. Invalid items are
+// answered with the errors of their fields.
+func (h *CartHandler) bindItem(c *gin.Context) (models.CartItemCreate, bool) {
+	var item models.CartItemCreate
+	if err := c.ShouldBindJSON(&item); err != nil {
+		if fields := fieldErrors(err, &item); fields != nil {
+			c.JSON(http.StatusBadRequest, models.ValidationErrorResponse{Error: "Validation failed", Fields: fields})
+		} else {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		}
+		return item, false
+	}
+
+	var fields []models.FieldError
+	if item.Quantity > h.maxQuantity {
+		fields = append(fields, models.FieldError{Field: "quantity", Message: fmt.Sprintf("must be at most %d", h.maxQuantity)})
+	}
+
+	status, _, err := h.products.fetch(c, http.MethodGet, "/product/info/"+strconv.Itoa(item.ProductID))
+	switch {
+	case err != nil:
+		h.products.abort(c, err)
+		return item, false
+	case status == http.StatusNotFound:
+		fields = append(fields, models.FieldError{Field: "product_id", Message: fmt.Sprintf("product %d does not exist", item.ProductID)})
+	case status != http.StatusOK:
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: "Failed to verify the product"})
+		return item, false
+	}
+
+	if len(fields) > 0 {
+		c.JSON(http.StatusBadRequest, models.ValidationErrorResponse{Error: "Validation failed", Fields: fields})
+		return item, false
+	}
+	return item, true
 }
 
 // Delete godoc
@@ -173,32 +182,5 @@ func (h *CartHandler) Update(c *gin.Context) {
 // @Router /cart/delete/{item_id} [delete]
 func (h *CartHandler) Delete(c *gin.Context) {
 	itemID := c.Param("item_id")
-	req, err := http.NewRequest("DELETE", h.serviceURL+"/cart/delete/"+itemID, nil)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to create request"})
-		return
-	}
-
-	copyHeaders(c.Request, req)
-
-	resp, err := h.client.Do(req)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to communicate with cart service"})
-		return
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusNoContent {
-		c.Status(http.StatusNoContent)
-		return
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to read response"})
-		return
-	}
-
-	c.Data(resp.StatusCode, "application/json", body)
+	h.upstream.Forward(c, "/cart/delete/"+itemID)
 }
-
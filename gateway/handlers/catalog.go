@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"gateway/models"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// productLookups bounds the concurrent product service calls of a request
+const productLookups = 8
+
+type productLookup struct {
+	product *models.Product
+	status  string
+}
+
+// lookupProducts fetches the products of the items concurrently, at most
+// productLookups at a time
+func lookupProducts(c *gin.Context, products *Upstream, items []models.CartItem) map[int]productLookup {
+	var ids []int
+	seen := make(map[int]bool, len(items))
+	for _, item := range items {
+		if !seen[item.ProductID] {
+			seen[item.ProductID] = true
+			ids = append(ids, item.ProductID)
+		}
+	}
+
+	lookups := make([]productLookup, len(ids))
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, productLookups)
+	for i, id := range ids {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i, id int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			lookups[i] = lookupProduct(c, products, id)
+		}(i, id)
+	}
+	wg.Wait()
+
+	results := make(map[int]productLookup, len(ids))
+	for i, id := range ids {
+		results[id] = lookups[i]
+	}
+	return results
+}
+
+func lookupProduct(c *gin.Context, products *Upstream, id int) productLookup {
+	status, body, err := products.fetch(c, http.MethodGet, "/product/info/"+strconv.Itoa(id))
+	switch {
+	case err != nil:
+		return productLookup{status: models.CartLineUnavailable}
+	case status == http.StatusNotFound:
+		return productLookup{status: models.CartLineNotFound}
+	case status != http.StatusOK:
+		return productLookup{status: models.CartLineUnavailable}
+	}
+
+	// The product service wraps the product in {"product": ...}
+	var info struct {
+		Product *models.Product `json:"product"`
+	}
+	if err := json.Unmarshal(body, &info); err != nil || info.Product == nil {
+		return productLookup{status: models.CartLineUnavailable}
+	}
+	return productLookup{product: info.Product, status: models.CartLineAvailable}
+}
+
+// roundPrice rounds to cents
+func roundPrice(price float64) float64 {
+	return math.Round(price*100) / 100
+}
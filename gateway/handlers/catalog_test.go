@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"gateway/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLookupProduct(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		wantStatus string
+		wantPrice  float64
+	}{
+		{"product", http.StatusOK, `{"product": {"id": 7, "name": "Cake", "price": 25.99}}`, models.CartLineAvailable, 25.99},
+		{"unwrapped product", http.StatusOK, `{"id": 7, "name": "Cake", "price": 25.99}`, models.CartLineUnavailable, 0},
+		{"malformed body", http.StatusOK, `{`, models.CartLineUnavailable, 0},
+		{"missing product", http.StatusNotFound, `{"detail": "Product not found"}`, models.CartLineNotFound, 0},
+		{"failing service", http.StatusInternalServerError, ``, models.CartLineUnavailable, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/product/info/7" {
+					t.Errorf("path = %s, want /product/info/7", r.URL.Path)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/cart/detailed", nil)
+			got := lookupProduct(c, NewUpstream("product", server.URL, server.Client()), 7)
+
+			if got.status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", got.status, tt.wantStatus)
+			}
+			if tt.wantStatus == models.CartLineAvailable && (got.product == nil || got.product.Price != tt.wantPrice) {
+				t.Errorf("product = %+v, want price %v", got.product, tt.wantPrice)
+			}
+		})
+	}
+}
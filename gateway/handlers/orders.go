@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gateway/middleware"
+	"gateway/models"
+	"gateway/orders"
+	"gateway/payments"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OrderHandler handles checkout and order related requests
+type OrderHandler struct {
+	store    orders.Store
+	payments *payments.Service
+	cart     *Upstream
+	products *Upstream
+}
+
+// NewOrderHandler creates a new order handler that places orders for the
+// carts of the cart upstream at the prices of the products upstream and
+// refunds the payments of cancelled orders
+func NewOrderHandler(store orders.Store, payments *payments.Service, cart, products *Upstream) *OrderHandler {
+	return &OrderHandler{store: store, payments: payments, cart: cart, products: products}
+}
+
+// Checkout godoc
+// @Summary Place an order
+// @Description Place an order for the items in the current user's cart at the current product prices and empty the cart. The order starts in status pending.
+// @Tags Orders
+// @Produce json
+// @Success 201 {object} models.Order
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /orders/checkout [post]
+func (h *OrderHandler) Checkout(c *gin.Context) {
+	status, body, err := h.cart.fetch(c, http.MethodGet, "/cart")
+	if err != nil {
+		h.cart.abort(c, err)
+		return
+	}
+	if status != http.StatusOK {
+		c.Data(status, "application/json", body)
+		return
+	}
+
+	var items []models.CartItem
+	if err := json.Unmarshal(body, &items); err != nil {
+		c.JSON(http.StatusBadGateway, models.ErrorResponse{Error: "Invalid response from cart service"})
+		return
+	}
+	if len(items) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Cart is empty"})
+		return
+	}
+
+	// Prices are taken from the product service at the moment of checkout
+	products := lookupProducts(c, h.products, items)
+	order := &models.Order{UserID: orderOwner(c), Status: models.OrderPending, Lines: make([]models.OrderLine, 0, len(items))}
+	for _, item := range items {
+		result := products[item.ProductID]
+		switch result.status {
+		case models.CartLineNotFound:
+			c.JSON(http.StatusConflict, models.ErrorResponse{Error: fmt.Sprintf("Product %d is no longer available", item.ProductID)})
+			return
+		case models.CartLineUnavailable:
+			c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: "Failed to look up the products of the cart"})
+			return
+		}
+
+		line := models.OrderLine{
+			ProductID: item.ProductID,
+			Name:      result.product.Name,
+			Photo:     result.product.Photo,
+			UnitPrice: result.product.Price,
+			Quantity:  item.Quantity,
+			LineTotal: roundPrice(result.product.Price * float64(item.Quantity)),
+		}
+		order.Total += line.LineTotal
+		order.Lines = append(order.Lines, line)
+	}
+	order.Total = roundPrice(order.Total)
+
+	if err := h.store.Create(c.Request.Context(), order); err != nil {
+		log.Printf("Failed to save order of user %s: %v", order.UserID, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to save the order"})
+		return
+	}
+
+	// The order is placed even if some items stay in the cart
+	for _, item := range items {
+		status, _, err := h.cart.fetch(c, http.MethodDelete, "/cart/delete/"+strconv.Itoa(item.ID))
+		if err == nil && status >= http.StatusMultipleChoices && status != http.StatusNotFound {
+			err = fmt.Errorf("status %d", status)
+		}
+		if err != nil {
+			log.Printf("Failed to remove cart item %d of order %d: %v", item.ID, order.ID, err)
+		}
+	}
+
+	c.JSON(http.StatusCreated, order)
+}
+
+// List godoc
+// @Summary List orders
+// @Description Get the orders of the current user, newest first
+// @Tags Orders
+// @Produce json
+// @Success 200 {array} models.Order
+// @Failure 401 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /orders [get]
+func (h *OrderHandler) List(c *gin.Context) {
+	list, err := h.store.List(c.Request.Context(), orderOwner(c))
+	if err != nil {
+		h.storeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// Get godoc
+// @Summary Get order
+// @Description Get an order of the current user
+// @Tags Orders
+// @Produce json
+// @Param id path int true "Order ID"
+// @Success 200 {object} models.Order
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /orders/{id} [get]
+func (h *OrderHandler) Get(c *gin.Context) {
+	order, ok := h.ownOrder(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, order)
+}
+
+// Cancel godoc
+// @Summary Cancel order
+// @Description Cancel an order of the current user that has not been shipped yet, paid orders are refunded
+// @Tags Orders
+// @Produce json
+// @Param id path int true "Order ID"
+// @Success 200 {object} models.Order
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 502 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /orders/{id}/cancel [post]
+func (h *OrderHandler) Cancel(c *gin.Context) {
+	order, ok := h.ownOrder(c)
+	if !ok {
+		return
+	}
+	h.cancel(c, order)
+}
+
+// cancel cancels the order, paid orders only through a refund of their payment
+func (h *OrderHandler) cancel(c *gin.Context, order *models.Order) {
+	cancelled, err := h.payments.CancelOrder(c.Request.Context(), order)
+	var transition *payments.TransitionError
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, cancelled)
+	case order.Status != models.OrderPaid:
+		h.storeError(c, err)
+	case errors.Is(err, payments.ErrNotFound):
+		log.Printf("Paid order %d has no payment to refund", order.ID)
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Order is paid but has no payment to refund"})
+	case errors.As(err, &transition):
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: fmt.Sprintf("Payment is %s and cannot be refunded", transition.From)})
+	case errors.As(err, new(*orders.TransitionError)):
+		h.storeError(c, err)
+	default:
+		log.Printf("Failed to cancel order %d: %v", order.ID, err)
+		c.JSON(http.StatusBadGateway, models.ErrorResponse{Error: "Failed to refund the order"})
+	}
+}
+
+// SetStatus godoc
+// @Summary Change order status
+// @Description Move an order of any user along pending, paid, shipped and delivered or cancel it, paid orders are refunded
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path int true "Order ID"
+// @Param status body models.OrderStatusUpdate true "New status"
+// @Success 200 {object} models.Order
+// @Failure 400 {object} models.ValidationErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 502 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/orders/{id}/status [post]
+func (h *OrderHandler) SetStatus(c *gin.Context) {
+	id, ok := orderID(c)
+	if !ok {
+		return
+	}
+
+	var update models.OrderStatusUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		if fields := fieldErrors(err, &update); fields != nil {
+			c.JSON(http.StatusBadRequest, models.ValidationErrorResponse{Error: "Validation failed", Fields: fields})
+		} else {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	if update.Status == models.OrderCancelled {
+		order, err := h.store.Get(c.Request.Context(), id)
+		if err != nil {
+			h.storeError(c, err)
+			return
+		}
+		h.cancel(c, order)
+		return
+	}
+	h.setStatus(c, id, update.Status)
+}
+
+func (h *OrderHandler) setStatus(c *gin.Context, id int64, status string) {
+	order, err := h.store.SetStatus(c.Request.Context(), id, status)
+	if err != nil {
+		h.storeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, order)
+}
+
+// ownOrder loads the order of the path and responds with 404 when it does
+// not exist or belongs to another user
+func (h *OrderHandler) ownOrder(c *gin.Context) (*models.Order, bool) {
+	id, ok := orderID(c)
+	if !ok {
+		return nil, false
+	}
+	order, err := h.store.Get(c.Request.Context(), id)
+	if err == nil && order.UserID != orderOwner(c) {
+		err = orders.ErrNotFound
+	}
+	if err != nil {
+		h.storeError(c, err)
+		return nil, false
+	}
+	return order, true
+}
+
+// storeError responds to a failed store operation
+func (h *OrderHandler) storeError(c *gin.Context, err error) {
+	var transition *orders.TransitionError
+	switch {
+	case errors.Is(err, orders.ErrNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Order not found"})
+	case errors.As(err, &transition):
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: fmt.Sprintf("Order is %s and cannot be %s", transition.From, transition.To)})
+	default:
+		log.Printf("Order store failed: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to access orders"})
+	}
+}
+
+func orderID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Order not found"})
+		return 0, false
+	}
+	return id, true
+}
+
+// orderOwner identifies the current user, the routes require a user id
+// so that a username can never match the id of another user
+func orderOwner(c *gin.Context) string {
+	user, _ := middleware.CurrentUser(c)
+	return user.ID
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"fmt"
+	"gateway/models"
+	"gateway/pagination"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Kinds of the cursors issued by the gateway, a cursor is only accepted by
+// the list it was issued for
+const (
+	productListCursor   = "product-list"
+	productSearchCursor = "product-search"
+)
+
+// parseLimit reads the limit parameter, limits above max are clamped
+func parseLimit(c *gin.Context, defaultLimit, maxLimit int) (int, *models.FieldError) {
+	value := c.Query("limit")
+	if value == "" {
+		return defaultLimit, nil
+	}
+	limit, err := strconv.Atoi(value)
+	switch {
+	case err != nil:
+		return 0, &models.FieldError{Field: "limit", Message: "must be an integer"}
+	case limit < 1:
+		return 0, &models.FieldError{Field: "limit", Message: "must be at least 1"}
+	}
+	return min(limit, maxLimit), nil
+}
+
+// pageRequest parses the limit and cursor parameters of a paginated list.
+// The deprecated skip parameter of the list before cursors is accepted as
+// the offset of the page, such pages do not realign when products change.
+func pageRequest(c *gin.Context, cursors *pagination.Signer, defaultLimit, maxLimit int) (int, pagination.Position, []models.FieldError) {
+	var fields []models.FieldError
+	limit, invalid := parseLimit(c, defaultLimit, maxLimit)
+	if invalid != nil {
+		fields = append(fields, *invalid)
+	}
+
+	var at pagination.Position
+	if value := c.Query("cursor"); value != "" {
+		if err := cursors.Decode(productListCursor, value, &at); err != nil || at.Offset < 0 {
+			fields = append(fields, models.FieldError{Field: "cursor", Message: "is invalid"})
+		}
+	}
+	if value, ok := c.GetQuery("skip"); ok {
+		skip, err := strconv.Atoi(value)
+		switch {
+		case err != nil || skip < 0:
+			fields = append(fields, models.FieldError{Field: "skip", Message: "must be a non-negative integer"})
+		case c.Query("cursor") != "":
+			fields = append(fields, models.FieldError{Field: "skip", Message: "cannot be combined with cursor"})
+		default:
+			at = pagination.Position{Offset: skip}
+			c.Header("Deprecation", "true")
+		}
+	}
+	return limit, at, fields
+}
+
+// pageLinks sets the RFC 8288 Link header of a page of the list at the
+// request path
+func pageLinks(c *gin.Context, limit int, first bool, next, prev string) {
+	link := func(cursor, rel string) string {
+		query := url.Values{"limit": {strconv.Itoa(limit)}}
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+		return fmt.Sprintf(`<%s?%s>; rel="%s"`, c.Request.URL.Path, query.Encode(), rel)
+	}
+
+	var links []string
+	if !first {
+		links = append(links, link("", "first"))
+	}
+	if prev != "" {
+		links = append(links, link(prev, "prev"))
+	}
+	if next != "" {
+		links = append(links, link(next, "next"))
+	}
+	if len(links) > 0 {
+		c.Header("Link", strings.Join(links, ", "))
+	}
+}
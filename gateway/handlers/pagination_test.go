@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"gateway/models"
+	"gateway/pagination"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPageRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cursors := pagination.NewSigner([]byte("secret"))
+	cursor := cursors.Encode(productListCursor, pagination.Position{Offset: 40, Anchor: 40})
+	searchCursor := cursors.Encode(productSearchCursor, pagination.Position{Offset: 40})
+
+	tests := []struct {
+		name           string
+		query          string
+		wantLimit      int
+		wantAt         pagination.Position
+		wantFields     []string
+		wantDeprecated bool
+	}{
+		{"defaults", "", 20, pagination.Position{}, nil, false},
+		{"limit is clamped", "limit=500", 100, pagination.Position{}, nil, false},
+		{"cursor", "cursor=" + cursor, 20, pagination.Position{Offset: 40, Anchor: 40}, nil, false},
+		{"search cursor", "cursor=" + searchCursor, 20, pagination.Position{}, []string{"cursor"}, false},
+		{"deprecated skip", "skip=30&limit=10", 10, pagination.Position{Offset: 30}, nil, true},
+		{"negative skip", "skip=-1", 20, pagination.Position{}, []string{"skip"}, false},
+		{"skip with cursor", "skip=30&cursor=" + cursor, 20, pagination.Position{Offset: 40, Anchor: 40}, []string{"skip"}, false},
+		{"invalid limit", "limit=0", 0, pagination.Position{}, []string{"limit"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/product/list?"+tt.query, nil)
+
+			limit, at, fields := pageRequest(c, cursors, 20, 100)
+			if limit != tt.wantLimit {
+				t.Errorf("limit = %d, want %d", limit, tt.wantLimit)
+			}
+			if len(tt.wantFields) == 0 && at != tt.wantAt {
+				t.Errorf("position = %+v, want %+v", at, tt.wantAt)
+			}
+			if got := fieldNames(fields); !reflect.DeepEqual(got, tt.wantFields) {
+				t.Errorf("invalid fields = %v, want %v", got, tt.wantFields)
+			}
+			if deprecated := w.Header().Get("Deprecation") == "true"; deprecated != tt.wantDeprecated {
+				t.Errorf("deprecated = %v, want %v", deprecated, tt.wantDeprecated)
+			}
+		})
+	}
+}
+
+func fieldNames(fields []models.FieldError) []string {
+	var names []string
+	for _, field := range fields {
+		names = append(names, field.Field)
+	}
+	return names
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"gateway/models"
+	"gateway/orders"
+	"gateway/payments"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PaymentHandler handles payments of orders and provider webhooks
+type PaymentHandler struct {
+	service  *payments.Service
+	provider payments.Provider
+	orders   orders.Store
+}
+
+// NewPaymentHandler creates a new payment handler
+func NewPaymentHandler(service *payments.Service, provider payments.Provider, orders orders.Store) *PaymentHandler {
+	return &PaymentHandler{service: service, provider: provider, orders: orders}
+}
+
+// Create godoc
+// @Summary Start a payment
+// @Description Start paying for a pending order of the current user. The payment charges the order total, which was snapshotted from the cart at checkout. An order has at most one open payment, starting it again returns the open one.
+// @Tags Payments
+// @Accept json
+// @Produce json
+// @Param payment body models.PaymentCreate true "Order to pay for"
+// @Success 200 {object} models.Payment
+// @Success 201 {object} models.Payment
+// @Failure 400 {object} models.ValidationErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 502 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /payments [post]
+func (h *PaymentHandler) Create(c *gin.Context) {
+	var create models.PaymentCreate
+	if err := c.ShouldBindJSON(&create); err != nil {
+		if fields := fieldErrors(err, &create); fields != nil {
+			c.JSON(http.StatusBadRequest, models.ValidationErrorResponse{Error: "Validation failed", Fields: fields})
+		} else {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+
+	order, err := h.orders.Get(c.Request.Context(), create.OrderID)
+	if err == nil && order.UserID != orderOwner(c) {
+		err = orders.ErrNotFound
+	}
+	if errors.Is(err, orders.ErrNotFound) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Order not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Order store failed: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to access orders"})
+		return
+	}
+
+	payment, created, err := h.service.Start(c.Request.Context(), order)
+	if err != nil {
+		h.paymentError(c, err)
+		return
+	}
+	if !created {
+		c.JSON(http.StatusOK, payment)
+		return
+	}
+	c.JSON(http.StatusCreated, payment)
+}
+
+// Get godoc
+// @Summary Get payment
+// @Description Get a payment of the current user
+// @Tags Payments
+// @Produce json
+// @Param id path int true "Payment ID"
+// @Success 200 {object} models.Payment
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /payments/{id} [get]
+func (h *PaymentHandler) Get(c *gin.Context) {
+	payment, ok := h.ownPayment(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, payment)
+}
+
+// Confirm godoc
+// @Summary Confirm a payment
+// @Description Charge the payment method for a payment of the current user. Succeeded payments mark their order paid. When the provider does not answer in time the payment stays processing until the provider reports the outcome. Confirming a succeeded payment again returns it unchanged.
+// @Tags Payments
+// @Accept json
+// @Produce json
+// @Param id path int true "Payment ID"
+// @Param confirm body models.PaymentConfirm false "Payment method"
+// @Success 200 {object} models.Payment
+// @Success 202 {object} models.Payment
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 402 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 502 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /payments/{id}/confirm [post]
+func (h *PaymentHandler) Confirm(c *gin.Context) {
+	payment, ok := h.ownPayment(c)
+	if !ok {
+		return
+	}
+
+	var confirm models.PaymentConfirm
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&confirm); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+
+	payment, err := h.service.Confirm(c.Request.Context(), payment, confirm.PaymentMethod)
+	if err != nil {
+		h.paymentError(c, err)
+		return
+	}
+	switch payment.Status {
+	case models.PaymentDeclined:
+		c.JSON(http.StatusPaymentRequired, models.ErrorResponse{Error: "Payment was declined"})
+	case models.PaymentProcessing:
+		c.JSON(http.StatusAccepted, payment)
+	default:
+		c.JSON(http.StatusOK, payment)
+	}
+}
+
+// Refund godoc
+// @Summary Refund a payment
+// @Description Return a succeeded payment to the customer and cancel its order unless it was shipped. Refunding a payment again returns it unchanged.
+// @Tags Admin
+// @Produce json
+// @Param id path int true "Payment ID"
+// @Success 200 {object} models.Payment
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 502 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/payments/{id}/refund [post]
+func (h *PaymentHandler) Refund(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Payment not found"})
+		return
+	}
+	payment, err := h.service.Get(c.Request.Context(), id)
+	if err == nil {
+		payment, err = h.service.Refund(c.Request.Context(), payment)
+	}
+	if err != nil {
+		h.paymentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, payment)
+}
+
+// Webhook godoc
+// @Summary Payment provider webhook
+// @Description Receive payment outcomes from the provider. Webhooks must carry a valid Webhook-Signature header, redelivered events are ignored.
+// @Tags Payments
+// @Accept json
+// @Param Webhook-Signature header string true "t=<unix seconds>,v1=<hex HMAC-SHA256 of t.payload>"
+// @Success 204 "No Content"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /webhooks/payments [post]
+func (h *PaymentHandler) Webhook(c *gin.Context) {
+	payload, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Failed to read the webhook"})
+		return
+	}
+
+	event, err := h.provider.VerifyWebhook(c.Request.Header, payload)
+	if errors.Is(err, payments.ErrInvalidSignature) {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Invalid signature"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := h.service.HandleEvent(c.Request.Context(), event); err != nil {
+		// The provider retries webhooks that were not accepted
+		log.Printf("Failed to apply payment webhook %s: %v", event.ID, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to apply the webhook"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ownPayment loads the payment of the path and responds with 404 when it
+// does not exist or belongs to another user
+func (h *PaymentHandler) ownPayment(c *gin.Context) (*models.Payment, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Payment not found"})
+		return nil, false
+	}
+	payment, err := h.service.Get(c.Request.Context(), id)
+	if err == nil && payment.UserID != orderOwner(c) {
+		err = payments.ErrNotFound
+	}
+	if err != nil {
+		h.paymentError(c, err)
+		return nil, false
+	}
+	return payment, true
+}
+
+// paymentError responds to a failed payment operation
+func (h *PaymentHandler) paymentError(c *gin.Context, err error) {
+	var transition *payments.TransitionError
+	var orderTransition *orders.TransitionError
+	switch {
+	case errors.Is(err, payments.ErrNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Payment not found"})
+	case errors.As(err, &transition):
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: fmt.Sprintf("Payment is %s and cannot be %s", transition.From, transition.To)})
+	case errors.As(err, &orderTransition):
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: fmt.Sprintf("Order is %s and cannot be paid", orderTransition.From)})
+	default:
+		log.Printf("Payment failed: %v", err)
+		c.JSON(http.StatusBadGateway, models.ErrorResponse{Error: "Payment provider failed"})
+	}
+}
@@ -3,61 +3,107 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"gateway/config"
+	"gateway/middleware"
 	"gateway/models"
-	"io"
+	"gateway/pagination"
+	"gateway/search"
+	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
 // ProductHandler handles product related requests
 type ProductHandler struct {
-	serviceURL string
-	client     *http.Client
+	upstream *Upstream
+	// cache of the catalogue reads, nil when caching is disabled
+	cache *middleware.ResponseCache
+	// index of the catalogue for searches
+	index    *search.Index
+	settings config.Search
+	// cursors signs the cursors of the list and the search
+	cursors    *pagination.Signer
+	pagination config.Pagination
 }
 
-// NewProductHandler creates a new product handler
-func NewProductHandler(serviceURL string) *ProductHandler {
-	return &ProductHandler{
-		serviceURL: serviceURL,
-		client:     &http.Client{},
-	}
+// NewProductHandler creates a new product handler that clears cache and
+// updates the search index when products change
+func NewProductHandler(upstream *Upstream, cache *middleware.ResponseCache, index *search.Index, settings config.Search, cursors *pagination.Signer, paging config.Pagination) *ProductHandler {
+	return &ProductHandler{upstream: upstream, cache: cache, index: index, settings: settings, cursors: cursors, pagination: paging}
 }
 
 // List godoc
-// @Summary List all products
-// @Description Get a list of all available products
+// @Summary List products
+// @Description Get a page of the available products in the order of their ids. The cursors
+// @Description of the response and the Link header fetch the next and previous page, they
+// @Description stay in place when products are added or removed before the page.
 // @Tags Product
 // @Produce json
-// @Param skip query int false "Number of products to skip" default(0)
-// @Param limit query int false "Maximum number of products to return" default(100)
-// @Success 200 {array} models.Product
+// @Param limit query int false "Maximum number of products to return, values above the maximum are clamped" default(20)
+// @Param cursor query string false "next_cursor or prev_cursor of another page"
+// @Param skip query int false "Deprecated, use cursor. Number of products to skip, the response carries a Deprecation header"
+// @Param If-None-Match header string false "ETag of a previously received page"
+// @Success 200 {object} models.ProductPage
+// @Success 304 "Not Modified"
+// @Header 200 {string} ETag "Validator of the response"
+// @Header 200 {string} Link "Links to the first, previous and next page"
+// @Header 200 {string} Deprecation "true for requests with skip"
+// @Failure 400 {object} models.ValidationErrorResponse
+// @Failure 502 {object} models.ErrorResponse
 // @Router /product/list [get]
 func (h *ProductHandler) List(c *gin.Context) {
-	req, err := http.NewRequest("GET", h.serviceURL+"/product/list", nil)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to create request"})
+	limit, at, fields := pageRequest(c, h.cursors, h.pagination.DefaultLimit, h.pagination.MaxLimit)
+	if len(fields) > 0 {
+		c.JSON(http.StatusBadRequest, models.ValidationErrorResponse{Error: "Validation failed", Fields: fields})
 		return
 	}
 
-	// Copy query parameters
-	req.URL.RawQuery = c.Request.URL.RawQuery
-	copyHeaders(c.Request, req)
-
-	resp, err := h.client.Do(req)
+	c.Set(middleware.UpstreamKey, h.upstream.Name())
+	window := pagination.NewWindow(at, limit)
+	path := fmt.Sprintf("/product/list?skip=%d&limit=%d", window.Skip, window.Limit)
+	status, header, body, err := h.upstream.fetchWithHeader(c, http.MethodGet, path)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to communicate with product service"})
+		h.upstream.abort(c, err)
+		return
+	}
+	c.Set(middleware.UpstreamStatusKey, status)
+	if status != http.StatusOK {
+		c.Data(status, gin.MIMEJSON, body)
+		return
+	}
+	var products []models.Product
+	if err := json.Unmarshal(body, &products); err != nil {
+		c.JSON(http.StatusBadGateway, models.ErrorResponse{Error: "Invalid response from product service"})
 		return
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	ids := make([]int, len(products))
+	for i, product := range products {
+		ids[i] = product.ID
+	}
+	located, err := window.Locate(at, limit, ids)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to read response"})
+		log.Printf("Cannot paginate the product list: %v", err)
+		c.JSON(http.StatusBadGateway, models.ErrorResponse{Error: "Invalid response from product service"})
 		return
 	}
 
-	c.Data(resp.StatusCode, "application/json", body)
+	page := models.ProductPage{Items: products[located.Start:located.End]}
+	if located.Next != nil {
+		page.NextCursor = h.cursors.Encode(productListCursor, located.Next)
+	}
+	if located.Prev != nil {
+		page.PrevCursor = h.cursors.Encode(productListCursor, located.Prev)
+	}
+	if total, err := strconv.Atoi(header.Get("X-Total-Count")); err == nil {
+		page.Total = &total
+	}
+
+	pageLinks(c, limit, located.Offset == 0, page.NextCursor, page.PrevCursor)
+	c.JSON(http.StatusOK, page)
 }
 
 // Add godoc
@@ -78,35 +124,9 @@ func (h *ProductHandler) Add(c *gin.Context) {
 		return
 	}
 
-	jsonData, err := json.Marshal(productCreate)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to marshal request"})
-		return
-	}
-
-	req, err := http.NewRequest("POST", h.serviceURL+"/product/add", bytes.NewBuffer(jsonData))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to create request"})
-		return
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	copyHeaders(c.Request, req)
-
-	resp, err := h.client.Do(req)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to communicate with product service"})
-		return
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to read response"})
-		return
-	}
-
-	c.Data(resp.StatusCode, "application/json", body)
+	body := teeBody(c)
+	h.upstream.ForwardJSON(c, "/product/add", productCreate)
+	h.invalidate(c, body)
 }
 
 // Update godoc
@@ -130,35 +150,51 @@ func (h *ProductHandler) Update(c *gin.Context) {
 		return
 	}
 
-	jsonData, err := json.Marshal(productCreate)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to marshal request"})
+	body := teeBody(c)
+	h.upstream.ForwardJSON(c, "/product/update/"+id, productCreate)
+	h.invalidate(c, body)
+}
+
+// invalidate clears the cached catalogue after a successful change and
+// indexes the product returned by the product service
+func (h *ProductHandler) invalidate(c *gin.Context, body *bytes.Buffer) {
+	if !c.Writer.Written() || c.Writer.Status() >= http.StatusMultipleChoices {
 		return
 	}
+	if h.cache != nil {
+		h.cache.Purge()
+	}
 
-	req, err := http.NewRequest("PUT", h.serviceURL+"/product/update/"+id, bytes.NewBuffer(jsonData))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to create request"})
+	var product models.Product
+	if err := json.Unmarshal(body.Bytes(), &product); err != nil || product.ID == 0 {
+		// The change is not known, the catalogue has to be loaded again
+		h.index.Invalidate()
 		return
 	}
+	h.index.Upsert(product)
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	copyHeaders(c.Request, req)
+// teeBody copies the response body written by the remaining code of the
+// handler into the returned buffer
+func teeBody(c *gin.Context) *bytes.Buffer {
+	writer := &teeWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+	return &writer.body
+}
 
-	resp, err := h.client.Do(req)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to communicate with product service"})
-		return
-	}
-	defer resp.Body.Close()
+type teeWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to read response"})
-		return
-	}
+func (w *teeWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
 
-	c.Data(resp.StatusCode, "application/json", body)
+func (w *teeWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
 }
 
 // Verify godoc
@@ -171,28 +207,7 @@ func (h *ProductHandler) Update(c *gin.Context) {
 // @Router /product/verify/{name} [get]
 func (h *ProductHandler) Verify(c *gin.Context) {
 	name := c.Param("name")
-	req, err := http.NewRequest("GET", h.serviceURL+"/product/verify/"+name, nil)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to create request"})
-		return
-	}
-
-	copyHeaders(c.Request, req)
-
-	resp, err := h.client.Do(req)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to communicate with product service"})
-		return
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to read response"})
-		return
-	}
-
-	c.Data(resp.StatusCode, "application/json", body)
+	h.upstream.Forward(c, "/product/verify/"+name)
 }
 
 // Info godoc
@@ -201,32 +216,13 @@ func (h *ProductHandler) Verify(c *gin.Context) {
 // @Tags Product
 // @Produce json
 // @Param id path int true "Product ID"
+// @Param If-None-Match header string false "ETag of a previously received product"
 // @Success 200 {object} models.Product
+// @Success 304 "Not Modified"
+// @Header 200 {string} ETag "Validator of the response"
 // @Failure 404 {object} models.ErrorResponse
 // @Router /product/info/{id} [get]
 func (h *ProductHandler) Info(c *gin.Context) {
 	id := c.Param("id")
-	req, err := http.NewRequest("GET", h.serviceURL+"/product/info/"+id, nil)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to create request"})
-		return
-	}
-
-	copyHeaders(c.Request, req)
-
-	resp, err := h.client.Do(req)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to communicate with product service"})
-		return
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to read response"})
-		return
-	}
-
-	c.Data(resp.StatusCode, "application/json", body)
+	h.upstream.Forward(c, "/product/info/"+id)
 }
-
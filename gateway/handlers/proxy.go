@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gateway/middleware"
+	"gateway/models"
+	"gateway/transport"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Upstream forwards requests to a single backend service
+type Upstream struct {
+	name    string
+	baseURL string
+	client  *http.Client
+}
+
+// NewUpstream creates a new upstream for the service available at baseURL
+func NewUpstream(name, baseURL string, client *http.Client) *Upstream {
+	return &Upstream{
+		name:    name,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  client,
+	}
+}
+
+// Name returns the name of the upstream service
+func (u *Upstream) Name() string {
+	return u.name
+}
+
+// Forward proxies the incoming request to path on the upstream service.
+// The request body is streamed as is.
+func (u *Upstream) Forward(c *gin.Context, path string) {
+	u.forward(c, path, c.Request.Body, c.Request.ContentLength, "")
+}
+
+// ForwardJSON proxies the incoming request to path on the upstream service
+// with payload as its JSON encoded body
+func (u *Upstream) ForwardJSON(c *gin.Context, path string, payload interface{}) {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to marshal request"})
+		return
+	}
+
+	u.forward(c, path, bytes.NewReader(jsonData), int64(len(jsonData)), "application/json")
+}
+
+func (u *Upstream) forward(c *gin.Context, path string, body io.Reader, contentLength int64, contentType string) {
+	// Cancelling the client request cancels the upstream call
+	req, err := http.NewRequestWithContext(c.Request.Context(), c.Request.Method, u.baseURL+path, body)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to create request"})
+		return
+	}
+
+	if contentLength > 0 {
+		req.ContentLength = contentLength
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	// Copy query parameters
+	req.URL.RawQuery = c.Request.URL.RawQuery
+	copyHeaders(c.Request, req)
+
+	c.Set(middleware.UpstreamKey, u.name)
+	resp, err := u.client.Do(req)
+	if err != nil {
+		u.abort(c, err)
+		return
+	}
+	defer resp.Body.Close()
+	c.Set(middleware.UpstreamStatusKey, resp.StatusCode)
+
+	// Copy cookies from the response
+	copyCookies(resp, c)
+
+	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		c.Status(resp.StatusCode)
+		return
+	}
+
+	c.DataFromReader(resp.StatusCode, resp.ContentLength, resp.Header.Get("Content-Type"), resp.Body, nil)
+}
+
+// fetch calls path on the upstream service with method on behalf of the
+// incoming request and returns the status and body of the response for the
+// gateway to use
+func (u *Upstream) fetch(c *gin.Context, method, path string) (int, []byte, error) {
+	status, _, body, err := u.fetchWithHeader(c, method, path)
+	return status, body, err
+}
+
+// fetchWithHeader is fetch that also returns the headers of the response
+func (u *Upstream) fetchWithHeader(c *gin.Context, method, path string) (int, http.Header, []byte, error) {
+	req, err := http.NewRequestWithContext(c.Request.Context(), method, u.baseURL+path, nil)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	copyHeaders(c.Request, req)
+	req.Header.Del("Content-Type")
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	return resp.StatusCode, resp.Header, body, nil
+}
+
+// Handle returns a handler that forwards requests to the target path template
+// on the upstream service. Path parameters such as :id are substituted from
+// the incoming request.
+func (u *Upstream) Handle(target string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u.Forward(c, expandPath(target, c.Params))
+	}
+}
+
+// expandPath substitutes :name and *name segments of the template with the
+// values of the matching path parameters
+func expandPath(template string, params gin.Params) string {
+	segments := strings.Split(template, "/")
+	for i, segment := range segments {
+		if len(segment) < 2 || (segment[0] != ':' && segment[0] != '*') {
+			continue
+		}
+		value, _ := params.Get(segment[1:])
+		if segment[0] == ':' {
+			value = url.PathEscape(value)
+		}
+		segments[i] = strings.TrimPrefix(value, "/")
+	}
+	return strings.Join(segments, "/")
+}
+
+// abort responds to a request the upstream could not serve
+func (u *Upstream) abort(c *gin.Context, err error) {
+	var open *transport.CircuitOpenError
+	if errors.As(err, &open) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(open.RetryAfter.Seconds()))))
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: fmt.Sprintf("The %s service is temporarily unavailable", u.name)})
+		return
+	}
+
+	if errors.Is(err, transport.ErrNoInstances) {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: fmt.Sprintf("The %s service is temporarily unavailable", u.name)})
+		return
+	}
+
+	if c.Request.Context().Err() != nil {
+		// The client went away, there is nobody to respond to
+		c.Abort()
+		return
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		c.JSON(http.StatusGatewayTimeout, models.ErrorResponse{Error: fmt.Sprintf("Timed out waiting for %s service", u.name)})
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: fmt.Sprintf("Failed to communicate with %s service", u.name)})
+}
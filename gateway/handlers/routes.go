@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Route describes a gateway endpoint served by an upstream service
+type Route struct {
+	// Method is the HTTP method of the endpoint
+	Method string
+	// Path is the gin path template relative to the router group, e.g. /update/:item_id
+	Path string
+	// Upstream is the name of the service the endpoint is served by
+	Upstream string
+	// Target is the upstream path template, defaults to the full gateway path
+	Target string
+	// Handler replaces plain forwarding when the endpoint needs custom handling
+	Handler gin.HandlerFunc
+	// Middleware runs before the handler of the endpoint
+	Middleware []gin.HandlerFunc
+}
+
+// Proxy exposes upstream service endpoints through the gateway
+type Proxy struct {
+	upstreams map[string]*Upstream
+}
+
+// NewProxy creates a new proxy for the given upstreams
+func NewProxy(upstreams ...*Upstream) *Proxy {
+	p := &Proxy{upstreams: make(map[string]*Upstream, len(upstreams))}
+	for _, u := range upstreams {
+		p.upstreams[u.name] = u
+	}
+	return p
+}
+
+// Upstream returns the upstream registered under name
+func (p *Proxy) Upstream(name string) (*Upstream, bool) {
+	u, ok := p.upstreams[name]
+	return u, ok
+}
+
+// Register adds the routes to the router group
+func (p *Proxy) Register(group *gin.RouterGroup, routes []Route) error {
+	for _, route := range routes {
+		u, ok := p.upstreams[route.Upstream]
+		if !ok {
+			return fmt.Errorf("route %s %s: unknown upstream %q", route.Method, route.Path, route.Upstream)
+		}
+
+		handler := route.Handler
+		if handler == nil {
+			target := route.Target
+			if target == "" {
+				target = joinPaths(group.BasePath(), route.Path)
+			}
+			handler = u.Handle(target)
+		}
+
+		handlers := append(append([]gin.HandlerFunc(nil), route.Middleware...), handler)
+		group.Handle(strings.ToUpper(route.Method), route.Path, handlers...)
+	}
+	return nil
+}
+
+func joinPaths(base, relative string) string {
+	if relative == "" {
+		return base
+	}
+	joined := path.Join(base, relative)
+	if strings.HasSuffix(relative, "/") && !strings.HasSuffix(joined, "/") {
+		joined += "/"
+	}
+	return joined
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gateway/models"
+	"gateway/search"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// catalogPageSize is the page size used to load the catalogue into the
+// search index
+const catalogPageSize = 100
+
+// Search godoc
+// @Summary Search products
+// @Description Full-text search over the name, descriptions and composition of products.
+// @Description Every word of q must match, words also match as prefixes of longer words.
+// @Description Results are ranked by relevance unless sorted otherwise.
+// @Tags Product
+// @Produce json
+// @Param q query string false "Search text, all products match when empty"
+// @Param min_price query number false "Lowest price"
+// @Param max_price query number false "Highest price"
+// @Param min_weight query number false "Lowest weight"
+// @Param max_weight query number false "Highest weight"
+// @Param sort query string false "Sort order" Enums(relevance, price, -price, weight, -weight, name, -name) default(relevance)
+// @Param limit query int false "Maximum number of products to return" default(20)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} models.ProductSearchResult
+// @Failure 400 {object} models.ValidationErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Router /product/search [get]
+func (h *ProductHandler) Search(c *gin.Context) {
+	query, fields := h.searchQuery(c)
+	if len(fields) > 0 {
+		c.JSON(http.StatusBadRequest, models.ValidationErrorResponse{Error: "Validation failed", Fields: fields})
+		return
+	}
+
+	page, err := h.index.Search(c.Request.Context(), query, h.loadCatalog(c))
+	if err != nil {
+		if c.Request.Context().Err() != nil {
+			c.Abort()
+			return
+		}
+		log.Printf("Failed to load the catalogue for search: %v", err)
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: "Search is temporarily unavailable"})
+		return
+	}
+
+	result := models.ProductSearchResult{Items: make([]models.ProductSearchHit, 0, len(page.Hits)), Total: page.Total}
+	for _, hit := range page.Hits {
+		result.Items = append(result.Items, models.ProductSearchHit{Product: hit.Product, Score: hit.Score})
+	}
+	if page.Next != nil {
+		result.NextCursor = query.EncodeCursor(h.cursors, productSearchCursor, page.Next)
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// searchQuery parses the query parameters of a search
+func (h *ProductHandler) searchQuery(c *gin.Context) (search.Query, []models.FieldError) {
+	var fields []models.FieldError
+	invalid := func(field, message string) {
+		fields = append(fields, models.FieldError{Field: field, Message: message})
+	}
+	bound := func(field string) *float64 {
+		value, ok := c.GetQuery(field)
+		if !ok || value == "" {
+			return nil
+		}
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			invalid(field, "must be a number")
+			return nil
+		}
+		return &number
+	}
+	span := func(name string) search.Range {
+		r := search.Range{Min: bound("min_" + name), Max: bound("max_" + name)}
+		if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+			invalid("max_"+name, "must not be less than min_"+name)
+		}
+		return r
+	}
+
+	query := search.Query{
+		Text:   c.Query("q"),
+		Price:  span("price"),
+		Weight: span("weight"),
+		Sort:   c.DefaultQuery("sort", search.SortRelevance),
+	}
+	if !validSort(query.Sort) {
+		invalid("sort", "must be one of "+strings.Join(search.Sorts, ", "))
+	}
+	limit, invalidLimit := parseLimit(c, h.settings.DefaultLimit, h.settings.MaxLimit)
+	if invalidLimit != nil {
+		fields = append(fields, *invalidLimit)
+	}
+	query.Limit = limit
+	if len(fields) > 0 {
+		return query, fields
+	}
+
+	if value := c.Query("cursor"); value != "" {
+		after, err := query.DecodeCursor(h.cursors, productSearchCursor, value)
+		if err != nil {
+			invalid("cursor", "is invalid or belongs to a different search")
+		}
+		query.After = after
+	}
+	return query, fields
+}
+
+func validSort(order string) bool {
+	for _, s := range search.Sorts {
+		if s == order {
+			return true
+		}
+	}
+	return false
+}
+
+// loadCatalog returns a function that reads the whole catalogue page by page
+// from the product service
+func (h *ProductHandler) loadCatalog(c *gin.Context) search.LoadFunc {
+	return func(ctx context.Context) ([]models.Product, error) {
+		var products []models.Product
+		for skip := 0; ; skip += catalogPageSize {
+			path := fmt.Sprintf("/product/list?skip=%d&limit=%d", skip, catalogPageSize)
+			status, body, err := h.upstream.fetch(c, http.MethodGet, path)
+			if err != nil {
+				return nil, err
+			}
+			if status != http.StatusOK {
+				return nil, fmt.Errorf("product service responded with status %d", status)
+			}
+
+			var page []models.Product
+			if err := json.Unmarshal(body, &page); err != nil {
+				return nil, fmt.Errorf("decode product list: %w", err)
+			}
+			products = append(products, page...)
+			if len(page) < catalogPageSize {
+				return products, nil
+			}
+		}
+	}
+}
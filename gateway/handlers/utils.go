@@ -1,9 +1,17 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gateway/middleware"
+	"gateway/models"
 	"net/http"
+	"reflect"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
 )
 
 // copyHeaders copies relevant headers from the incoming request to the outgoing request
@@ -26,6 +34,24 @@ func copyHeaders(from *http.Request, to *http.Request) {
 	if accept := from.Header.Get("Accept"); accept != "" {
 		to.Header.Set("Accept", accept)
 	}
+
+	// Copy request id for correlation of logs
+	if requestID := from.Header.Get(middleware.RequestIDHeader); requestID != "" {
+		to.Header.Set(middleware.RequestIDHeader, requestID)
+	}
+
+	// Copy identity of the user authenticated by the gateway
+	if userID := from.Header.Get(middleware.UserIDHeader); userID != "" {
+		to.Header.Set(middleware.UserIDHeader, userID)
+	}
+
+	if username := from.Header.Get(middleware.UsernameHeader); username != "" {
+		to.Header.Set(middleware.UsernameHeader, username)
+	}
+
+	if secret := from.Header.Get(middleware.GatewaySecretHeader); secret != "" {
+		to.Header.Set(middleware.GatewaySecretHeader, secret)
+	}
 }
 
 // copyCookies copies cookies from HTTP response to Gin context
@@ -35,3 +61,60 @@ func copyCookies(from *http.Response, to *gin.Context) {
 	}
 }
 
+// fieldErrors converts the binding errors of obj to field errors named after
+// the JSON keys, it returns nil for errors of malformed bodies
+func fieldErrors(err error, obj interface{}) []models.FieldError {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return []models.FieldError{{Field: typeErr.Field, Message: "must be " + typeName(typeErr.Type)}}
+	}
+
+	var invalid validator.ValidationErrors
+	if !errors.As(err, &invalid) {
+		return nil
+	}
+
+	t := reflect.TypeOf(obj)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	fields := make([]models.FieldError, 0, len(invalid))
+	for _, e := range invalid {
+		name := e.Field()
+		if field, ok := t.FieldByName(e.StructField()); ok {
+			if tag, _, _ := strings.Cut(field.Tag.Get("json"), ","); tag != "" {
+				name = tag
+			}
+		}
+
+		message := "is invalid"
+		switch e.Tag() {
+		case "required":
+			message = "is required"
+		case "min":
+			message = fmt.Sprintf("must be at least %s", e.Param())
+		case "max":
+			message = fmt.Sprintf("must be at most %s", e.Param())
+		case "oneof":
+			message = fmt.Sprintf("must be one of %s", strings.ReplaceAll(e.Param(), " ", ", "))
+		}
+		fields = append(fields, models.FieldError{Field: name, Message: message})
+	}
+	return fields
+}
+
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	default:
+		return "of type " + t.String()
+	}
+}
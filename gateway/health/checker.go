@@ -0,0 +1,200 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Dependency states
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Check probes the health route of an upstream service
+type Check struct {
+	Name string
+	// URLs returns the health routes of the current instances, the
+	// dependency is up while one of them answers
+	URLs func() []string
+	// Critical dependencies make the gateway unready when they are down
+	Critical bool
+}
+
+// DependencyStatus is the outcome of a single check
+type DependencyStatus struct {
+	Name       string  `json:"name" example:"product"`
+	Status     string  `json:"status" example:"up"`
+	Critical   bool    `json:"critical" example:"true"`
+	LatencyMs  float64 `json:"latency_ms" example:"3.2"`
+	StatusCode int     `json:"status_code,omitempty" example:"200"`
+	Error      string  `json:"error,omitempty"`
+	// InstancesUp is reported for dependencies with several instances
+	InstancesUp int `json:"instances_up,omitempty" example:"2"`
+	Instances   int `json:"instances,omitempty" example:"3"`
+}
+
+// Report is the readiness of the gateway and its dependencies
+type Report struct {
+	// Status is ok, degraded when a non-critical dependency is down,
+	// unavailable when a critical one is down and draining during shutdown
+	Status       string             `json:"status" example:"ok"`
+	CheckedAt    time.Time          `json:"checked_at"`
+	Dependencies []DependencyStatus `json:"dependencies"`
+}
+
+// Checker probes upstream services concurrently and caches the results
+type Checker struct {
+	status *Status
+	checks []Check
+	client *http.Client
+	ttl    time.Duration
+
+	mu     sync.Mutex
+	report *Report
+}
+
+// NewChecker creates a checker that gives every probe timeout to answer and
+// reuses results for ttl
+func NewChecker(status *Status, checks []Check, timeout, ttl time.Duration) *Checker {
+	return &Checker{
+		status: status,
+		checks: checks,
+		client: &http.Client{Timeout: timeout},
+		ttl:    ttl,
+	}
+}
+
+// Check returns the cached report or probes the dependencies when it expired
+func (ch *Checker) Check(ctx context.Context) Report {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	if ch.report != nil && time.Since(ch.report.CheckedAt) < ch.ttl {
+		return *ch.report
+	}
+
+	report := Report{
+		Status:       "ok",
+		CheckedAt:    time.Now(),
+		Dependencies: make([]DependencyStatus, len(ch.checks)),
+	}
+
+	var wg sync.WaitGroup
+	for i, check := range ch.checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			report.Dependencies[i] = ch.probe(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	for _, dependency := range report.Dependencies {
+		if dependency.Status == StatusUp {
+			continue
+		}
+		if dependency.Critical {
+			report.Status = "unavailable"
+			break
+		}
+		report.Status = "degraded"
+	}
+
+	ch.report = &report
+	return report
+}
+
+// probe checks every instance of the dependency, it is up when one of them is
+func (ch *Checker) probe(ctx context.Context, check Check) DependencyStatus {
+	urls := check.URLs()
+	results := make([]DependencyStatus, len(urls))
+	var wg sync.WaitGroup
+	for i, url := range urls {
+		wg.Add(1)
+		go func(i int, url string) {
+			defer wg.Done()
+			results[i] = ch.probeURL(ctx, url)
+		}(i, url)
+	}
+	wg.Wait()
+
+	result := DependencyStatus{Status: StatusDown}
+	if len(urls) == 0 {
+		result.Error = "no instances discovered"
+	}
+	up := 0
+	for _, r := range results {
+		if r.Status == StatusUp {
+			if up == 0 {
+				result = r
+			}
+			up++
+		} else if up == 0 {
+			result = r
+		}
+	}
+
+	result.Name, result.Critical = check.Name, check.Critical
+	if len(urls) > 1 {
+		result.InstancesUp, result.Instances = up, len(urls)
+	}
+	return result
+}
+
+func (ch *Checker) probeURL(ctx context.Context, url string) (result DependencyStatus) {
+	result = DependencyStatus{Status: StatusDown}
+
+	start := time.Now()
+	defer func() {
+		result.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	resp, err := ch.client.Do(req)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	result.StatusCode = resp.StatusCode
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+		result.Status = StatusUp
+	} else {
+		result.Error = fmt.Sprintf("unexpected status %d", resp.StatusCode)
+	}
+	return result
+}
+
+// Readyz reports whether the gateway can serve traffic with a breakdown of
+// its dependencies. It responds with 503 while draining or when a critical
+// dependency is down.
+func (ch *Checker) Readyz(c *gin.Context) {
+	if ch.status.Draining() {
+		c.JSON(http.StatusServiceUnavailable, Report{Status: "draining", CheckedAt: time.Now()})
+		return
+	}
+
+	// Probes outlive the request so that a disconnecting client does not
+	// poison the cache with cancelled results
+	report := ch.Check(context.Background())
+
+	code := http.StatusOK
+	if report.Status == "unavailable" {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, report)
+}
@@ -0,0 +1,50 @@
+package health
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Status tracks whether the gateway should receive traffic
+type Status struct {
+	draining atomic.Bool
+}
+
+// NewStatus creates the status of a gateway that accepts traffic
+func NewStatus() *Status {
+	return &Status{}
+}
+
+// Drain marks the gateway as shutting down so readiness checks start failing
+func (s *Status) Drain() {
+	s.draining.Store(true)
+}
+
+// Draining reports whether the gateway is shutting down
+func (s *Status) Draining() bool {
+	return s.draining.Load()
+}
+
+// Healthcheck responds with 200 while the gateway accepts traffic and with
+// 503 once it is draining
+func (s *Status) Healthcheck(c *gin.Context) {
+	if s.Draining() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status": "draining",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+	})
+}
+
+// Livez reports that the gateway process is running
+func (s *Status) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+	})
+}
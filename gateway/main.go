@@ -1,77 +1,146 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"gateway/config"
-	"gateway/handlers"
-	"gateway/middleware"
-	"github.com/gin-gonic/gin"
-	swaggerFiles "github.com/swaggo/files"
-	ginSwagger "github.com/swaggo/gin-swagger"
+	"gateway/health"
+	"gateway/logging"
+	"gateway/orders"
+	"gateway/payments"
+	"gateway/tracing"
 	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	_ "gateway/docs"
 )
 
 func main() {
-	// Получаем порт из переменной окружения или используем значение по умолчанию
-	port := config.App.Gateway_port
-
-	// Получаем URL'ы сервисов из переменных окружения
-	authServiceURL := config.App.Auth_service_url
-	productServiceURL := config.App.Product_service_url
-	cartServiceURL := config.App.Cart_service_url
-
-	router := gin.Default()
-
-	// Добавляем CORS middleware
-	router.Use(middleware.CORSMiddleware())
-
-	// Создаем handlers
-	authHandler := handlers.NewAuthHandler(authServiceURL)
-	productHandler := handlers.NewProductHandler(productServiceURL)
-	cartHandler := handlers.NewCartHandler(cartServiceURL)
-
-	// Auth routes
-	authGroup := router.Group("/auth")
-	{
-		authGroup.POST("/register", authHandler.Register)
-		authGroup.POST("/login", authHandler.Login)
-		authGroup.POST("/logout", authHandler.Logout)
-		authGroup.GET("/info", authHandler.Info)
+	// Конфигурация: значения по умолчанию, YAML файл, переменные окружения и флаги
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
-	// Product routes
-	productGroup := router.Group("/product")
-	{
-		productGroup.GET("/list", productHandler.List)
-		productGroup.POST("/add", productHandler.Add)
-		productGroup.PUT("/update/:id", productHandler.Update)
-		productGroup.GET("/verify/:name", productHandler.Verify)
-		productGroup.GET("/info/:id", productHandler.Info)
+	// Структурированные логи, стандартный log пишет через тот же handler
+	logger, err := logging.New(os.Stdout, cfg.Log.Level, cfg.Log.Format)
+	if err != nil {
+		log.Fatalf("Failed to configure logging: %v", err)
 	}
+	slog.SetDefault(logger)
 
-	// Cart routes
-	cartGroup := router.Group("/cart")
-	{
-		cartGroup.GET("", cartHandler.Get)
-		cartGroup.POST("/add", cartHandler.Add)
-		cartGroup.PUT("/update/:item_id", cartHandler.Update)
-		cartGroup.DELETE("/delete/:item_id", cartHandler.Delete)
+	// Трассировка запросов через gateway и upstream сервисы
+	shutdownTracing, err := tracing.Setup(context.Background(), "gateway", tracing.Config{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
 	}
 
-	// Healthcheck
-	router.GET("/healthcheck", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"status": "ok",
-		})
-	})
+	// Хранилища заказов и платежей общие для всех версий конфигурации
+	orderStore, paymentStore, closeStores, err := openStores(cfg.Orders)
+	if err != nil {
+		log.Fatalf("Failed to open order store: %v", err)
+	}
+	defer closeStores()
+	log.Printf("Payments use the fake provider, orders are not charged and their charges %s", cfg.Payments.FakeOutcome)
+	paymentProvider := payments.NewFake(cfg.Payments.FakeOutcome, cfg.Payments.WebhookSecret)
+	paymentService := payments.NewService(paymentProvider, paymentStore, orderStore, cfg.Payments.Currency, cfg.Payments.Timeout)
 
-	// Swagger documentation
-	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	// Маршруты и middleware собираются заново при каждом изменении конфигурации,
+	// запросы в обработке дорабатывают со старой версией
+	gw := newGateway(logger, orderStore, paymentService, paymentProvider)
+	reloader := config.NewReloader(os.Args[1:], gw.apply, logger)
+	gw.reloader = reloader
+	if err := reloader.Init(cfg); err != nil {
+		log.Fatalf("Failed to apply configuration: %v", err)
+	}
 
 	// Запускаем сервер
-	log.Printf("Starting Gateway on port %s", port)
-	if err := router.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	server := &http.Server{
+		Addr:              ":" + strconv.Itoa(cfg.Server.Port),
+		Handler:           gw,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+
+	go func() {
+		log.Printf("Starting Gateway on port %d", cfg.Server.Port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	// Ждем SIGTERM/SIGINT и корректно завершаем обработку запросов,
+	// до этого перечитываем конфигурацию по SIGHUP и при изменении файла
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	go reloader.Watch(ctx)
+	<-ctx.Done()
+	stop()
+
+	shutdown(server, gw.status, cfg.Shutdown)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		log.Printf("Failed to flush traces: %v", err)
+	}
+}
+
+// openStores creates the order store of the configuration and the payment
+// store next to it, payments of stored orders must outlive a restart as
+// well. The returned function releases both.
+func openStores(settings config.Orders) (orders.Store, payments.Store, func(), error) {
+	if settings.Store != config.OrdersPostgres {
+		return orders.NewMemoryStore(), payments.NewMemoryStore(), func() {}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	orderStore, err := orders.OpenSQLStore(ctx, settings.DSN)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	paymentStore, err := payments.OpenSQLStore(ctx, settings.DSN)
+	if err != nil {
+		orderStore.Close()
+		return nil, nil, nil, err
+	}
+	return orderStore, paymentStore, func() {
+		if err := orderStore.Close(); err != nil {
+			log.Printf("Failed to close order store: %v", err)
+		}
+		if err := paymentStore.Close(); err != nil {
+			log.Printf("Failed to close payment store: %v", err)
+		}
+	}, nil
+}
+
+// shutdown fails readiness, waits for it to propagate and drains in-flight
+// requests within the grace period
+func shutdown(server *http.Server, status *health.Status, settings config.Shutdown) {
+	log.Printf("Shutting down, draining connections for up to %s", settings.Delay+settings.GracePeriod)
+
+	status.Drain()
+	server.SetKeepAlivesEnabled(false)
+	time.Sleep(settings.Delay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), settings.GracePeriod)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Gateway stopped before all requests were drained: %v", err)
+		return
 	}
+	log.Printf("Gateway stopped")
 }
@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "gateway"
+
+// UnmatchedRoute labels requests that did not match any route, keeping the
+// cardinality of the route label bounded
+const UnmatchedRoute = "unmatched"
+
+// Metrics holds the Prometheus collectors of the gateway
+type Metrics struct {
+	registry *prometheus.Registry
+
+	// Requests counts handled requests by route template, method and status
+	Requests *prometheus.CounterVec
+	// RequestDuration observes request latency by route template, method and status
+	RequestDuration *prometheus.HistogramVec
+	// InFlight counts requests being handled by route template and method
+	InFlight *prometheus.GaugeVec
+
+	// UpstreamRequests counts upstream calls by service, method and outcome
+	UpstreamRequests *prometheus.CounterVec
+	// UpstreamDuration observes upstream call latency by service, method and outcome
+	UpstreamDuration *prometheus.HistogramVec
+	// UpstreamErrors counts failed upstream calls by service and error kind
+	UpstreamErrors *prometheus.CounterVec
+
+	// CacheRequests counts response cache lookups by route template and result
+	CacheRequests *prometheus.CounterVec
+	// CoalescedRequests counts requests answered with the response of an
+	// identical concurrent request by route template
+	CoalescedRequests *prometheus.CounterVec
+	// IdempotentReplays counts retried requests answered with the stored
+	// response of their Idempotency-Key by route template
+	IdempotentReplays *prometheus.CounterVec
+}
+
+// New creates the gateway collectors in a dedicated registry together with
+// the Go runtime and process collectors
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		Requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP requests handled by the gateway.",
+		}, []string{"route", "method", "status"}),
+		RequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of HTTP requests handled by the gateway.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		InFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "Number of HTTP requests currently handled by the gateway.",
+		}, []string{"route", "method"}),
+		UpstreamRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "upstream_requests_total",
+			Help:      "Number of calls to upstream services.",
+		}, []string{"service", "method", "outcome"}),
+		UpstreamDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "upstream_request_duration_seconds",
+			Help:      "Latency of calls to upstream services.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"service", "method", "outcome"}),
+		UpstreamErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "upstream_errors_total",
+			Help:      "Number of failed calls to upstream services by error kind.",
+		}, []string{"service", "kind"}),
+		CacheRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_requests_total",
+			Help:      "Number of response cache lookups by result: hit, miss or collapsed into a concurrent miss.",
+		}, []string{"route", "result"}),
+		CoalescedRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "coalesced_requests_total",
+			Help:      "Number of requests answered with the response of an identical concurrent request, i.e. upstream calls saved.",
+		}, []string{"route"}),
+		IdempotentReplays: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "idempotent_replays_total",
+			Help:      "Number of retried requests answered with the stored response of their Idempotency-Key.",
+		}, []string{"route"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.Requests,
+		m.RequestDuration,
+		m.InFlight,
+		m.UpstreamRequests,
+		m.UpstreamDuration,
+		m.UpstreamErrors,
+		m.CacheRequests,
+		m.CoalescedRequests,
+		m.IdempotentReplays,
+	)
+	return m
+}
+
+// Register adds further collectors to the registry of the gateway
+func (m *Metrics) Register(cs ...prometheus.Collector) {
+	m.registry.MustRegister(cs...)
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func (m *Metrics) Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"gateway/transport"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Upstream error kinds
+const (
+	ErrorTimeout           = "timeout"
+	ErrorConnectionRefused = "connection_refused"
+	ErrorCircuitOpen       = "circuit_open"
+	ErrorCanceled          = "canceled"
+	ErrorNon2xx            = "non_2xx"
+	ErrorOther             = "other"
+)
+
+// InstrumentUpstream returns a transport middleware recording every call to
+// the upstream service
+func (m *Metrics) InstrumentUpstream(service string) transport.Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return transport.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			elapsed := time.Since(start).Seconds()
+
+			outcome := ""
+			switch {
+			case err != nil:
+				outcome = errorKind(err)
+				m.UpstreamErrors.WithLabelValues(service, outcome).Inc()
+			default:
+				outcome = strconv.Itoa(resp.StatusCode/100) + "xx"
+				if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+					m.UpstreamErrors.WithLabelValues(service, ErrorNon2xx).Inc()
+				}
+			}
+
+			m.UpstreamRequests.WithLabelValues(service, req.Method, outcome).Inc()
+			m.UpstreamDuration.WithLabelValues(service, req.Method, outcome).Observe(elapsed)
+			return resp, err
+		})
+	}
+}
+
+func errorKind(err error) string {
+	var open *transport.CircuitOpenError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &open):
+		return ErrorCircuitOpen
+	case errors.Is(err, context.Canceled):
+		return ErrorCanceled
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorConnectionRefused
+	}
+	return ErrorOther
+}
@@ -0,0 +1,222 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"gateway/models"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// AccessTokenCookie is the cookie the auth service stores the access token in
+	AccessTokenCookie = "access_token"
+
+	// UserIDHeader carries the id of the authenticated user to upstream services
+	UserIDHeader = "X-User-Id"
+	// UsernameHeader carries the username of the authenticated user to upstream services
+	UsernameHeader = "X-Username"
+	// GatewaySecretHeader carries the upstream secret that vouches for the
+	// identity headers
+	GatewaySecretHeader = "X-Gateway-Secret"
+
+	userKey      = "auth.user"
+	authErrorKey = "auth.error"
+)
+
+// User represents the authenticated user of a request
+type User struct {
+	ID       string
+	Username string
+	Roles    []string
+}
+
+// accessClaims are the claims of access tokens issued by the auth service
+type accessClaims struct {
+	UserID interface{}      `json:"user_id,omitempty"`
+	Role   string           `json:"role,omitempty"`
+	Roles  jwt.ClaimStrings `json:"roles,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// JWTVerifier verifies access tokens issued by the auth service
+type JWTVerifier struct {
+	key    interface{}
+	parser *jwt.Parser
+}
+
+// NewJWTVerifier creates a verifier for tokens signed with algorithm. HMAC
+// algorithms use the shared secret, asymmetric ones the PEM encoded public key
+// stored in publicKeyFile.
+func NewJWTVerifier(algorithm, secret, publicKeyFile string) (*JWTVerifier, error) {
+	if algorithm == "" {
+		algorithm = jwt.SigningMethodHS256.Alg()
+	}
+
+	method := jwt.GetSigningMethod(algorithm)
+	if method == nil {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+
+	var key interface{}
+	switch method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if secret == "" {
+			return nil, fmt.Errorf("algorithm %s requires a shared secret", algorithm)
+		}
+		key = []byte(secret)
+	default:
+		if publicKeyFile == "" {
+			return nil, fmt.Errorf("algorithm %s requires a public key", algorithm)
+		}
+		pem, err := os.ReadFile(publicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read public key: %w", err)
+		}
+		key, err = parsePublicKey(method, pem)
+		if err != nil {
+			return nil, fmt.Errorf("parse public key: %w", err)
+		}
+	}
+
+	return &JWTVerifier{
+		key: key,
+		parser: jwt.NewParser(
+			jwt.WithValidMethods([]string{algorithm}),
+			jwt.WithExpirationRequired(),
+			jwt.WithLeeway(30*time.Second),
+		),
+	}, nil
+}
+
+func parsePublicKey(method jwt.SigningMethod, pem []byte) (interface{}, error) {
+	switch method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		return jwt.ParseRSAPublicKeyFromPEM(pem)
+	case *jwt.SigningMethodECDSA:
+		return jwt.ParseECPublicKeyFromPEM(pem)
+	case *jwt.SigningMethodEd25519:
+		return jwt.ParseEdPublicKeyFromPEM(pem)
+	}
+	return nil, fmt.Errorf("unsupported signing method %s", method.Alg())
+}
+
+// Verify checks the signature, expiry and subject of the token and returns its user
+func (v *JWTVerifier) Verify(token string) (*User, error) {
+	var claims accessClaims
+	_, err := v.parser.ParseWithClaims(token, &claims, func(*jwt.Token) (interface{}, error) {
+		return v.key, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+
+	user := &User{Username: claims.Subject, Roles: claims.Roles}
+	if claims.Role != "" {
+		user.Roles = append(user.Roles, claims.Role)
+	}
+	if claims.UserID != nil {
+		user.ID = fmt.Sprint(claims.UserID)
+	}
+	return user, nil
+}
+
+// Authenticate verifies the access token of the request when one is present
+// and passes the authenticated user to upstream services in trusted headers
+// together with upstreamSecret. Requests without a valid token continue
+// anonymously; use RequireAuth to reject them.
+func Authenticate(verifier *JWTVerifier, upstreamSecret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Never trust identity headers sent by the client
+		c.Request.Header.Del(UserIDHeader)
+		c.Request.Header.Del(UsernameHeader)
+		c.Request.Header.Del(GatewaySecretHeader)
+
+		token := accessToken(c.Request)
+		if token == "" {
+			c.Next()
+			return
+		}
+
+		user, err := verifier.Verify(token)
+		if err != nil {
+			c.Set(authErrorKey, err)
+			c.Next()
+			return
+		}
+
+		c.Set(userKey, user)
+		if user.ID != "" {
+			c.Request.Header.Set(UserIDHeader, user.ID)
+		}
+		c.Request.Header.Set(UsernameHeader, user.Username)
+		c.Request.Header.Set(GatewaySecretHeader, upstreamSecret)
+
+		c.Next()
+	}
+}
+
+// RequireAuth rejects requests that were not authenticated by Authenticate
+func RequireAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := CurrentUser(c); ok {
+			c.Next()
+			return
+		}
+
+		message := "Not authenticated"
+		if err, ok := c.Get(authErrorKey); ok {
+			message = fmt.Sprintf("Invalid access token: %v", err)
+		}
+
+		c.Header("WWW-Authenticate", "Bearer")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, models.ErrorResponse{Error: message})
+	}
+}
+
+// RequireUserID rejects users whose token carries no user id, for routes
+// that store data under the id of the user
+func RequireUserID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if user, ok := CurrentUser(c); ok && user.ID != "" {
+			c.Next()
+			return
+		}
+
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Access token has no user id, sign in again"})
+	}
+}
+
+// CurrentUser returns the authenticated user of the request
+func CurrentUser(c *gin.Context) (*User, bool) {
+	value, ok := c.Get(userKey)
+	if !ok {
+		return nil, false
+	}
+	user, ok := value.(*User)
+	return user, ok
+}
+
+// accessToken extracts the token from the access_token cookie or the
+// Authorization header
+func accessToken(r *http.Request) string {
+	value := r.Header.Get("Authorization")
+	if cookie, err := r.Cookie(AccessTokenCookie); err == nil && cookie.Value != "" {
+		value = cookie.Value
+	}
+
+	if len(value) > len("Bearer ") && strings.EqualFold(value[:len("Bearer ")], "Bearer ") {
+		return strings.TrimSpace(value[len("Bearer "):])
+	}
+	return strings.TrimSpace(value)
+}
@@ -0,0 +1,216 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"gateway/config"
+	"gateway/metrics"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Cache lookup results
+const (
+	CacheHit       = "hit"
+	CacheMiss      = "miss"
+	CacheCollapsed = "collapsed"
+)
+
+// CacheKeyFunc returns the cache key of a request, requests it returns false
+// for bypass the cache
+type CacheKeyFunc func(c *gin.Context) (string, bool)
+
+// ResponseCache keeps the successful responses of read-only routes in memory
+// and lets concurrent misses of a key share a single upstream call
+type ResponseCache struct {
+	ttl        time.Duration
+	maxEntries int
+	metrics    *metrics.Metrics
+	now        func() time.Time
+	flights    *flightGroup
+
+	mu      sync.Mutex
+	entries map[string]*cachedResponse
+	// generation changes on every purge so that calls started before it do
+	// not store stale responses
+	generation uint64
+}
+
+type cachedResponse struct {
+	*recordedResponse
+	etag    string
+	expires time.Time
+}
+
+// NewResponseCache creates an empty cache with the settings
+func NewResponseCache(settings config.Cache, m *metrics.Metrics) *ResponseCache {
+	return &ResponseCache{
+		ttl:        settings.TTL,
+		maxEntries: settings.MaxEntries,
+		metrics:    m,
+		now:        time.Now,
+		flights:    newFlightGroup(),
+		entries:    make(map[string]*cachedResponse),
+	}
+}
+
+// PathKey keys requests on their path
+func PathKey(c *gin.Context) (string, bool) {
+	return c.Request.URL.Path, true
+}
+
+// QueryKey keys requests on their path and query with the integer parameters
+// normalized and defaulted, requests with invalid values bypass the cache
+func QueryKey(defaults map[string]int) CacheKeyFunc {
+	return func(c *gin.Context) (string, bool) {
+		query := c.Request.URL.Query()
+		normalized := make(url.Values, len(query)+len(defaults))
+		for name, values := range query {
+			normalized[name] = append([]string(nil), values...)
+		}
+		for name, fallback := range defaults {
+			value := fallback
+			if raw := query.Get(name); raw != "" {
+				n, err := strconv.Atoi(raw)
+				if err != nil {
+					return "", false
+				}
+				value = n
+			}
+			normalized.Set(name, strconv.Itoa(value))
+		}
+		return c.Request.URL.Path + "?" + normalized.Encode(), true
+	}
+}
+
+// Middleware serves responses of the route from the cache. Fresh responses
+// carry an ETag and requests with a matching If-None-Match get 304 Not
+// Modified.
+func (rc *ResponseCache) Middleware(key CacheKeyFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		k, ok := key(c)
+		if !ok {
+			c.Next()
+			return
+		}
+		route := c.FullPath()
+
+		rc.mu.Lock()
+		entry, ok := rc.entries[k]
+		generation := rc.generation
+		rc.mu.Unlock()
+		if ok && rc.now().Before(entry.expires) {
+			rc.record(route, CacheHit)
+			rc.serve(c, entry, "HIT")
+			return
+		}
+
+		var fetched *cachedResponse
+		response, shared := rc.flights.do(c, k, func(response *recordedResponse) {
+			fetched = rc.newEntry(response)
+			if response.status != http.StatusOK {
+				return
+			}
+			rc.mu.Lock()
+			defer rc.mu.Unlock()
+			if generation == rc.generation {
+				rc.store(k, fetched)
+			}
+		})
+
+		switch {
+		case response == nil && shared && c.Request.Context().Err() == nil:
+			// The request shared produced no response, serve this one on its own
+			c.Next()
+		case response == nil:
+			c.Abort()
+		case shared:
+			rc.record(route, CacheCollapsed)
+			rc.serve(c, rc.newEntry(response), "HIT")
+		default:
+			rc.record(route, CacheMiss)
+			rc.serve(c, fetched, "MISS")
+		}
+	}
+}
+
+// Purge drops every cached response
+func (rc *ResponseCache) Purge() {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.entries = make(map[string]*cachedResponse)
+	rc.generation++
+}
+
+func (rc *ResponseCache) newEntry(response *recordedResponse) *cachedResponse {
+	sum := sha256.Sum256(response.body)
+	return &cachedResponse{
+		recordedResponse: response,
+		etag:             `"` + hex.EncodeToString(sum[:16]) + `"`,
+		expires:          rc.now().Add(rc.ttl),
+	}
+}
+
+// store adds the response, the caller holds rc.mu
+func (rc *ResponseCache) store(key string, response *cachedResponse) {
+	if len(rc.entries) >= rc.maxEntries {
+		now := rc.now()
+		for k, entry := range rc.entries {
+			if !now.Before(entry.expires) {
+				delete(rc.entries, k)
+			}
+		}
+	}
+	// Still full, drop an arbitrary entry
+	for k := range rc.entries {
+		if len(rc.entries) < rc.maxEntries {
+			break
+		}
+		delete(rc.entries, k)
+	}
+	rc.entries[key] = response
+}
+
+// serve writes the response and skips the handlers of the route
+func (rc *ResponseCache) serve(c *gin.Context, response *cachedResponse, result string) {
+	header := c.Writer.Header()
+	header.Set("X-Cache", result)
+	if response.status != http.StatusOK {
+		response.write(c)
+		return
+	}
+
+	// Clients revalidate before every use so that updates show immediately
+	header.Set("Cache-Control", "no-cache")
+	header.Set("ETag", response.etag)
+	if etagMatches(c.GetHeader("If-None-Match"), response.etag) {
+		c.Abort()
+		header.Del("Content-Length")
+		c.Status(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+	response.write(c)
+}
+
+func (rc *ResponseCache) record(route, result string) {
+	rc.metrics.CacheRequests.WithLabelValues(route, result).Inc()
+}
+
+// etagMatches reports whether the If-None-Match header lists etag, weak
+// validators match as well
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"gateway/metrics"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Coalescer lets identical concurrent GET requests of anonymous clients share
+// a single call to the upstream service
+type Coalescer struct {
+	routes  map[string]bool
+	metrics *metrics.Metrics
+	flights *flightGroup
+}
+
+// NewCoalescer creates a coalescer for the route templates
+func NewCoalescer(routes []string, m *metrics.Metrics) *Coalescer {
+	co := &Coalescer{
+		routes:  make(map[string]bool, len(routes)),
+		metrics: m,
+		flights: newFlightGroup(),
+	}
+	for _, route := range routes {
+		co.routes[route] = true
+	}
+	return co
+}
+
+// Middleware makes requests wait for an identical request in flight and
+// answers them with its response. Requests carrying credentials are never
+// shared since their responses may depend on the client.
+func (co *Coalescer) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if c.Request.Method != http.MethodGet || !co.routes[route] ||
+			accessToken(c.Request) != "" || c.GetHeader(APIKeyHeader) != "" {
+			c.Next()
+			return
+		}
+
+		response, shared := co.flights.do(c, coalesceKey(c.Request), nil)
+		switch {
+		case response == nil && shared && c.Request.Context().Err() == nil:
+			// The request shared produced no response, serve this one on its own
+			c.Next()
+		case response == nil:
+			c.Abort()
+		case shared:
+			co.metrics.CoalescedRequests.WithLabelValues(route).Inc()
+			response.write(c)
+		default:
+			response.write(c)
+		}
+	}
+}
+
+// coalesceKey identifies identical requests. Conditional requests are only
+// shared with requests carrying the same validators, their response may be
+// an empty 304 Not Modified.
+func coalesceKey(r *http.Request) string {
+	return r.URL.RequestURI() + "\n" + r.Header.Get("If-None-Match") + "\n" + r.Header.Get("If-Modified-Since")
+}
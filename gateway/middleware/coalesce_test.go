@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"gateway/metrics"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCoalescerConditionalRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name        string
+		first       string
+		second      string
+		wantCalls   int32
+		wantSecond  int
+		wantSharing bool
+	}{
+		{"identical requests share", "", "", 1, http.StatusOK, true},
+		{"identical conditional requests share", `"v1"`, `"v1"`, 1, http.StatusNotModified, true},
+		{"conditional leader is not shared", `"v1"`, "", 2, http.StatusOK, false},
+		{"different validators are not shared", `"v1"`, `"v2"`, 2, http.StatusNotModified, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			started := make(chan struct{}, 2)
+			release := make(chan struct{})
+			coalescer := NewCoalescer([]string{"/product/list"}, metrics.New())
+			router := gin.New()
+			router.Use(coalescer.Middleware())
+			router.GET("/product/list", func(c *gin.Context) {
+				atomic.AddInt32(&calls, 1)
+				started <- struct{}{}
+				<-release
+				if c.GetHeader("If-None-Match") != "" {
+					c.Status(http.StatusNotModified)
+					c.Writer.WriteHeaderNow()
+					return
+				}
+				c.String(http.StatusOK, "products")
+			})
+
+			request := func(validator string) *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/product/list", nil)
+				if validator != "" {
+					req.Header.Set("If-None-Match", validator)
+				}
+				return req
+			}
+			serve := func(validator string) *httptest.ResponseRecorder {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, request(validator))
+				return w
+			}
+
+			var wg sync.WaitGroup
+			var second *httptest.ResponseRecorder
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				serve(tt.first)
+			}()
+			<-started
+			go func() {
+				defer wg.Done()
+				second = serve(tt.second)
+			}()
+			if !tt.wantSharing {
+				select {
+				case <-started:
+				case <-time.After(time.Second):
+					t.Error("second request was not served on its own")
+				}
+			} else {
+				// Release the leader only once the second request joined its flight
+				key := coalesceKey(request(tt.second))
+				deadline := time.Now().Add(time.Second)
+				for coalescer.flights.waiters(key) < 1 {
+					if time.Now().After(deadline) {
+						t.Fatal("second request did not join the flight")
+					}
+					runtime.Gosched()
+				}
+			}
+			close(release)
+			wg.Wait()
+
+			if got := atomic.LoadInt32(&calls); got != tt.wantCalls {
+				t.Errorf("handler calls = %d, want %d", got, tt.wantCalls)
+			}
+			if second.Code != tt.wantSecond {
+				t.Errorf("second status = %d, want %d", second.Code, tt.wantSecond)
+			}
+			if tt.wantSecond == http.StatusOK && second.Body.String() != "products" {
+				t.Errorf("second body = %q, want %q", second.Body.String(), "products")
+			}
+		})
+	}
+}
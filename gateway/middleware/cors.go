@@ -1,23 +1,224 @@
 package middleware
 
 import (
+	"fmt"
+	"gateway/config"
+	"gateway/models"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
 	"github.com/gin-gonic/gin"
 )
 
-// CORSMiddleware handles Cross-Origin Resource Sharing
-func CORSMiddleware() gin.HandlerFunc {
+// CORS applies the cross-origin policy of the route group a request belongs to
+type CORS struct {
+	// policies are ordered by the longest path first, the base policy has an empty path
+	policies []*corsPolicy
+}
+
+type corsPolicy struct {
+	path string
+
+	anyOrigin bool
+	origins   map[string]bool
+	wildcards []wildcardOrigin
+	patterns  []*regexp.Regexp
+
+	methods      map[string]bool
+	allowMethods string
+	headers      map[string]bool
+	allowHeaders string
+	exposed      string
+	credentials  bool
+	maxAge       string
+}
+
+// wildcardOrigin matches origins like https://*.example.com
+type wildcardOrigin struct {
+	prefix, suffix string
+}
+
+// NewCORS compiles the CORS settings and their route group overrides
+func NewCORS(settings config.CORS) (*CORS, error) {
+	base, err := newCORSPolicy("", settings)
+	if err != nil {
+		return nil, err
+	}
+
+	cors := &CORS{policies: []*corsPolicy{base}}
+	for _, override := range settings.Overrides {
+		policy, err := newCORSPolicy(override.Path, settings.Override(override))
+		if err != nil {
+			return nil, fmt.Errorf("cors override %s: %w", override.Path, err)
+		}
+		cors.policies = append(cors.policies, policy)
+	}
+	sort.SliceStable(cors.policies, func(i, j int) bool {
+		return len(cors.policies[i].path) > len(cors.policies[j].path)
+	})
+	return cors, nil
+}
+
+func newCORSPolicy(path string, settings config.CORS) (*corsPolicy, error) {
+	p := &corsPolicy{
+		path:        path,
+		origins:     make(map[string]bool),
+		methods:     make(map[string]bool),
+		headers:     make(map[string]bool),
+		exposed:     strings.Join(settings.ExposedHeaders, ", "),
+		credentials: settings.AllowCredentials,
+	}
+
+	for _, origin := range settings.AllowedOrigins {
+		switch {
+		case origin == config.AnyOrigin:
+			p.anyOrigin = true
+		case strings.Contains(origin, "://*."):
+			prefix, suffix, _ := strings.Cut(origin, "*")
+			p.wildcards = append(p.wildcards, wildcardOrigin{prefix: strings.ToLower(prefix), suffix: strings.ToLower(suffix)})
+		default:
+			p.origins[strings.ToLower(origin)] = true
+		}
+	}
+	for _, pattern := range settings.AllowedOriginPatterns {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("origin pattern %q: %w", pattern, err)
+		}
+		p.patterns = append(p.patterns, re)
+	}
+
+	methods := make([]string, 0, len(settings.AllowedMethods))
+	for _, method := range settings.AllowedMethods {
+		method = strings.ToUpper(method)
+		p.methods[method] = true
+		methods = append(methods, method)
+	}
+	p.allowMethods = strings.Join(methods, ", ")
+
+	for _, header := range settings.AllowedHeaders {
+		p.headers[strings.ToLower(header)] = true
+	}
+	p.allowHeaders = strings.Join(settings.AllowedHeaders, ", ")
+
+	if settings.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(settings.MaxAge.Seconds()))
+	}
+	return p, nil
+}
+
+// Middleware answers preflight requests and adds the CORS headers to the
+// responses of allowed origins. Preflights of origins, methods or headers the
+// policy does not allow are rejected with 403 Forbidden.
+func (cors *CORS) Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		policy := cors.policyFor(c.Request.URL.Path)
+		header := c.Writer.Header()
+		header.Add("Vary", "Origin")
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
 			return
 		}
+		allowed := policy.allowsOrigin(origin)
+
+		requestedMethod := c.GetHeader("Access-Control-Request-Method")
+		if c.Request.Method == http.MethodOptions && requestedMethod != "" {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
 
+			switch {
+			case !allowed:
+				c.AbortWithStatusJSON(http.StatusForbidden, models.ErrorResponse{Error: "Origin not allowed"})
+				return
+			case !policy.methods[strings.ToUpper(requestedMethod)]:
+				c.AbortWithStatusJSON(http.StatusForbidden, models.ErrorResponse{Error: "Method not allowed"})
+				return
+			case !policy.allowsHeaders(c.GetHeader("Access-Control-Request-Headers")):
+				c.AbortWithStatusJSON(http.StatusForbidden, models.ErrorResponse{Error: "Headers not allowed"})
+				return
+			}
+
+			policy.setOrigin(header, origin)
+			header.Set("Access-Control-Allow-Methods", policy.allowMethods)
+			if policy.allowHeaders != "" {
+				header.Set("Access-Control-Allow-Headers", policy.allowHeaders)
+			}
+			if policy.maxAge != "" {
+				header.Set("Access-Control-Max-Age", policy.maxAge)
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		if allowed {
+			policy.setOrigin(header, origin)
+			if policy.exposed != "" {
+				header.Set("Access-Control-Expose-Headers", policy.exposed)
+			}
+		}
 		c.Next()
 	}
 }
 
+// policyFor returns the policy of the longest override matching path
+func (cors *CORS) policyFor(path string) *corsPolicy {
+	for _, policy := range cors.policies {
+		if strings.HasPrefix(path, policy.path) {
+			return policy
+		}
+	}
+	return cors.policies[len(cors.policies)-1]
+}
+
+func (p *corsPolicy) allowsOrigin(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+
+	lower := strings.ToLower(origin)
+	if p.origins[lower] {
+		return true
+	}
+	for _, w := range p.wildcards {
+		if strings.HasPrefix(lower, w.prefix) && strings.HasSuffix(lower, w.suffix) {
+			subdomain := lower[len(w.prefix) : len(lower)-len(w.suffix)]
+			if subdomain != "" && !strings.ContainsAny(subdomain, "/:@") {
+				return true
+			}
+		}
+	}
+	for _, re := range p.patterns {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsHeaders reports whether every header of the comma separated list is allowed
+func (p *corsPolicy) allowsHeaders(requested string) bool {
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.ToLower(strings.TrimSpace(header))
+		if header != "" && !p.headers[header] {
+			return false
+		}
+	}
+	return true
+}
+
+// setOrigin echoes the allowed origin, a literal * is only sent without credentials
+func (p *corsPolicy) setOrigin(header http.Header, origin string) {
+	if p.anyOrigin && !p.credentials {
+		header.Set("Access-Control-Allow-Origin", config.AnyOrigin)
+		return
+	}
+	header.Set("Access-Control-Allow-Origin", origin)
+	if p.credentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
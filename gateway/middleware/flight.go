@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// flightGroup lets concurrent requests with the same key share the response
+// of the first one instead of each running the handlers of the route
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+type flight struct {
+	done     chan struct{}
+	response *recordedResponse
+	// waiters counts the requests that joined the flight
+	waiters int
+}
+
+// recordedResponse is a buffered response of the handlers of a route
+type recordedResponse struct {
+	status      int
+	contentType string
+	// header holds the headers the handlers set, such as Link
+	header http.Header
+	body   []byte
+}
+
+func newFlightGroup() *flightGroup {
+	return &flightGroup{flights: make(map[string]*flight)}
+}
+
+// do runs the remaining handlers for the first request of key with the
+// response buffered and passes it to landed before the waiting requests get
+// it. shared reports whether the response is the one of another request. The
+// response is nil when the handlers wrote none or the waiting request was
+// cancelled.
+func (g *flightGroup) do(c *gin.Context, key string, landed func(*recordedResponse)) (response *recordedResponse, shared bool) {
+	g.mu.Lock()
+	if f, ok := g.flights[key]; ok {
+		f.waiters++
+		g.mu.Unlock()
+		select {
+		case <-f.done:
+			return f.response, true
+		case <-c.Request.Context().Done():
+			return nil, true
+		}
+	}
+	f := &flight{done: make(chan struct{})}
+	g.flights[key] = f
+	g.mu.Unlock()
+
+	// The waiting requests must be released even if a handler panics
+	defer func() {
+		g.mu.Lock()
+		delete(g.flights, key)
+		g.mu.Unlock()
+		close(f.done)
+	}()
+
+	f.response = record(c)
+	if f.response != nil && landed != nil {
+		landed(f.response)
+	}
+	return f.response, false
+}
+
+// waiters returns the number of requests waiting for the flight of key
+func (g *flightGroup) waiters(key string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if f, ok := g.flights[key]; ok {
+		return f.waiters
+	}
+	return 0
+}
+
+// record runs the remaining handlers with the response buffered
+func record(c *gin.Context) *recordedResponse {
+	before := c.Writer.Header().Clone()
+	recorder := &responseRecorder{ResponseWriter: c.Writer}
+	c.Writer = recorder
+	defer func() { c.Writer = recorder.ResponseWriter }()
+	c.Next()
+
+	if recorder.status == 0 {
+		return nil
+	}
+	header := changedHeaders(before, c.Writer.Header())
+	// The response may be shared with other clients
+	header.Del("Set-Cookie")
+	return &recordedResponse{
+		status:      recorder.status,
+		contentType: c.Writer.Header().Get("Content-Type"),
+		header:      header,
+		body:        recorder.body.Bytes(),
+	}
+}
+
+// write sends the response and skips the handlers of the route, headers
+// already set on the response are kept
+func (r *recordedResponse) write(c *gin.Context) {
+	c.Abort()
+	header := c.Writer.Header()
+	for name, values := range r.header {
+		if _, ok := header[name]; !ok {
+			header[name] = append([]string(nil), values...)
+		}
+	}
+	c.Data(r.status, r.contentType, r.body)
+}
+
+// responseRecorder buffers a response instead of writing it
+type responseRecorder struct {
+	gin.ResponseWriter
+	status  int
+	written bool
+	body    bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	if !r.written {
+		r.status = code
+	}
+}
+
+func (r *responseRecorder) WriteHeaderNow() {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.written = true
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.WriteHeaderNow()
+	return r.body.Write(data)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.WriteHeaderNow()
+	return r.body.WriteString(s)
+}
+
+func (r *responseRecorder) Status() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+func (r *responseRecorder) Size() int {
+	return r.body.Len()
+}
+
+func (r *responseRecorder) Written() bool {
+	return r.written
+}
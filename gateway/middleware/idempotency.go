@@ -0,0 +1,264 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gateway/config"
+	"gateway/metrics"
+	"gateway/models"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// IdempotencyKeyHeader identifies retries of the same POST, PUT or DELETE request
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader marks responses replayed for a retry
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// IdempotencyKeys remembers the responses of requests with an
+// Idempotency-Key header so that retries are not executed again. The keys
+// outlive configuration reloads.
+type IdempotencyKeys struct {
+	metrics *metrics.Metrics
+	now     func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*idempotentRequest
+}
+
+// idempotentRequest is the first request of a key, done is closed once its
+// response is stored or dropped
+type idempotentRequest struct {
+	fingerprint string
+	done        chan struct{}
+	response    *recordedResponse
+	header      http.Header
+	expires     time.Time
+}
+
+// NewIdempotencyKeys creates an empty key store
+func NewIdempotencyKeys(m *metrics.Metrics) *IdempotencyKeys {
+	return &IdempotencyKeys{
+		metrics: m,
+		now:     time.Now,
+		entries: make(map[string]*idempotentRequest),
+	}
+}
+
+// Middleware executes POST, PUT and DELETE requests once per Idempotency-Key
+// and user and replays the status, headers and body of the first response to
+// retries within the TTL. A retry arriving while the first request is in
+// flight waits for its response. Reusing a key with a different request is
+// rejected with 409 while the first one is in flight and 422 afterwards.
+// Server errors and 429 are not stored so that retries are executed. The
+// excluded routes are never replayed.
+func (k *IdempotencyKeys) Middleware(settings config.Idempotency, excluded ...string) gin.HandlerFunc {
+	skip := make(map[string]bool, len(excluded))
+	for _, route := range excluded {
+		skip[route] = true
+	}
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" || c.FullPath() == "" || skip[c.FullPath()] || !idempotentMethod(c.Request.Method) {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, models.ErrorResponse{Error: "Idempotency-Key must be at most 255 characters"})
+			return
+		}
+
+		// The body is kept for the fingerprint, bound it before reading
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, int64(settings.MaxBodyBytes)))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, models.ErrorResponse{Error: fmt.Sprintf("Request body must be at most %d bytes", tooLarge.Limit)})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, models.ErrorResponse{Error: "Failed to read the request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		fingerprint := requestFingerprint(c.Request, body)
+		scope := idempotencyScope(c) + "\x00" + key
+		for {
+			entry, first := k.claim(scope, fingerprint, settings.MaxEntries)
+			if first {
+				k.execute(c, scope, entry, settings.TTL)
+				return
+			}
+
+			if entry.fingerprint != fingerprint {
+				select {
+				case <-entry.done:
+					if entry.response == nil {
+						continue
+					}
+					c.AbortWithStatusJSON(http.StatusUnprocessableEntity, models.ErrorResponse{Error: "Idempotency-Key was already used for a different request"})
+				default:
+					c.AbortWithStatusJSON(http.StatusConflict, models.ErrorResponse{Error: "A different request with this Idempotency-Key is in progress"})
+				}
+				return
+			}
+
+			select {
+			case <-entry.done:
+			case <-c.Request.Context().Done():
+				c.Abort()
+				return
+			}
+			if entry.response == nil {
+				// The first request stored no response, execute this one instead
+				continue
+			}
+
+			header := c.Writer.Header()
+			for name, values := range entry.header {
+				header[name] = append([]string(nil), values...)
+			}
+			header.Set(IdempotentReplayedHeader, "true")
+			k.metrics.IdempotentReplays.WithLabelValues(c.FullPath()).Inc()
+			entry.response.write(c)
+			return
+		}
+	}
+}
+
+// claim returns the live request of the scoped key or registers a new one,
+// first reports whether the caller has to execute it
+func (k *IdempotencyKeys) claim(scope, fingerprint string, maxEntries int) (entry *idempotentRequest, first bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	now := k.now()
+	if entry, ok := k.entries[scope]; ok {
+		if !entry.completed() || now.Before(entry.expires) {
+			return entry, false
+		}
+		delete(k.entries, scope)
+	}
+
+	if len(k.entries) >= maxEntries {
+		for key, entry := range k.entries {
+			if entry.completed() && !now.Before(entry.expires) {
+				delete(k.entries, key)
+			}
+		}
+	}
+	// Still full, drop an arbitrary stored response, requests in flight stay
+	for key, entry := range k.entries {
+		if len(k.entries) < maxEntries {
+			break
+		}
+		if entry.completed() {
+			delete(k.entries, key)
+		}
+	}
+
+	entry = &idempotentRequest{fingerprint: fingerprint, done: make(chan struct{})}
+	k.entries[scope] = entry
+	return entry, true
+}
+
+// execute runs the remaining handlers and stores their response for the key
+func (k *IdempotencyKeys) execute(c *gin.Context, scope string, entry *idempotentRequest, ttl time.Duration) {
+	before := c.Writer.Header().Clone()
+
+	// Waiting retries must be released even if a handler panics
+	stored := false
+	defer func() {
+		k.mu.Lock()
+		if !stored && k.entries[scope] == entry {
+			delete(k.entries, scope)
+		}
+		k.mu.Unlock()
+		close(entry.done)
+	}()
+
+	response := record(c)
+	if response == nil {
+		return
+	}
+	if response.status < http.StatusInternalServerError && response.status != http.StatusTooManyRequests {
+		k.mu.Lock()
+		entry.response = response
+		entry.header = changedHeaders(before, c.Writer.Header())
+		entry.expires = k.now().Add(ttl)
+		k.mu.Unlock()
+		stored = true
+	}
+	response.write(c)
+}
+
+// completed reports whether the response of the request is stored, the
+// caller holds the lock of the key store
+func (r *idempotentRequest) completed() bool {
+	return r.response != nil
+}
+
+func idempotentMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// idempotencyScope separates the keys of users, anonymous clients are told
+// apart by their address
+func idempotencyScope(c *gin.Context) string {
+	if user, ok := CurrentUser(c); ok {
+		if user.ID != "" {
+			return "user:" + user.ID
+		}
+		return "username:" + user.Username
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// requestFingerprint identifies the method, target and body of the request
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	io.WriteString(hash, r.Method+" "+r.URL.RequestURI()+"\n")
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// changedHeaders returns the response headers the handlers set, the headers
+// of earlier middleware are set again for every request
+func changedHeaders(before, after http.Header) http.Header {
+	changed := make(http.Header)
+	for name, values := range after {
+		if name == "Content-Length" || equalValues(before[name], values) {
+			continue
+		}
+		changed[name] = append([]string(nil), values...)
+	}
+	return changed
+}
+
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
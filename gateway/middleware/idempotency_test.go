@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"gateway/config"
+	"gateway/metrics"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var testIdempotency = config.Idempotency{Enabled: true, TTL: time.Hour, MaxEntries: 100, MaxBodyBytes: 64}
+
+// idempotentRouter serves path with the middleware, the handler responds
+// with status and counts its calls. The X-Test-User header authenticates.
+func idempotentRouter(path string, status int, block <-chan struct{}, calls *int32) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if id := c.GetHeader("X-Test-User"); id != "" {
+			c.Set(userKey, &User{ID: id})
+		}
+	})
+	router.Use(NewIdempotencyKeys(metrics.New()).Middleware(testIdempotency, "/webhooks/payments"))
+	router.POST(path, func(c *gin.Context) {
+		n := atomic.AddInt32(calls, 1)
+		if block != nil {
+			<-block
+		}
+		c.String(status, "call %d", n)
+	})
+	return router
+}
+
+func serveIdempotent(router *gin.Engine, path, user, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set(IdempotencyKeyHeader, "key-1")
+	if user != "" {
+		req.Header.Set("X-Test-User", user)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	type request struct{ user, body string }
+	tests := []struct {
+		name         string
+		path         string
+		status       int
+		requests     []request
+		wantCalls    int32
+		wantStatus   int
+		wantReplayed bool
+	}{
+		{"retry is replayed", "/orders/checkout", http.StatusCreated, []request{{"1", "a"}, {"1", "a"}}, 1, http.StatusCreated, true},
+		{"client errors are replayed", "/orders/checkout", http.StatusBadRequest, []request{{"1", "a"}, {"1", "a"}}, 1, http.StatusBadRequest, true},
+		{"different body is rejected", "/orders/checkout", http.StatusCreated, []request{{"1", "a"}, {"1", "b"}}, 1, http.StatusUnprocessableEntity, false},
+		{"server errors are executed again", "/orders/checkout", http.StatusBadGateway, []request{{"1", "a"}, {"1", "a"}}, 2, http.StatusBadGateway, false},
+		{"rate limited requests are executed again", "/orders/checkout", http.StatusTooManyRequests, []request{{"1", "a"}, {"1", "a"}}, 2, http.StatusTooManyRequests, false},
+		{"users have their own keys", "/orders/checkout", http.StatusCreated, []request{{"1", "a"}, {"2", "a"}}, 2, http.StatusCreated, false},
+		{"anonymous and user keys are apart", "/orders/checkout", http.StatusCreated, []request{{"", "a"}, {"1", "a"}}, 2, http.StatusCreated, false},
+		{"excluded route is executed again", "/webhooks/payments", http.StatusOK, []request{{"", "a"}, {"", "a"}}, 2, http.StatusOK, false},
+		{"body over the limit", "/orders/checkout", http.StatusCreated, []request{{"1", strings.Repeat("a", 65)}}, 0, http.StatusRequestEntityTooLarge, false},
+		{"body at the limit", "/orders/checkout", http.StatusCreated, []request{{"1", strings.Repeat("a", 64)}}, 1, http.StatusCreated, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			router := idempotentRouter(tt.path, tt.status, nil, &calls)
+
+			var first, last *httptest.ResponseRecorder
+			for _, r := range tt.requests {
+				last = serveIdempotent(router, tt.path, r.user, r.body)
+				if first == nil {
+					first = last
+				}
+			}
+
+			if calls := atomic.LoadInt32(&calls); calls != tt.wantCalls {
+				t.Errorf("handler calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if last.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", last.Code, tt.wantStatus)
+			}
+			if replayed := last.Header().Get(IdempotentReplayedHeader) == "true"; replayed != tt.wantReplayed {
+				t.Errorf("replayed = %v, want %v", replayed, tt.wantReplayed)
+			}
+			if tt.wantReplayed && last.Body.String() != first.Body.String() {
+				t.Errorf("replayed body = %q, want %q", last.Body.String(), first.Body.String())
+			}
+		})
+	}
+}
+
+func TestIdempotencyMiddlewareInFlight(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	router := idempotentRouter("/orders/checkout", http.StatusCreated, release, &calls)
+
+	first := make(chan *httptest.ResponseRecorder)
+	go func() { first <- serveIdempotent(router, "/orders/checkout", "1", "a") }()
+	for atomic.LoadInt32(&calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if w := serveIdempotent(router, "/orders/checkout", "1", "b"); w.Code != http.StatusConflict {
+		t.Errorf("different request in flight status = %d, want %d", w.Code, http.StatusConflict)
+	}
+
+	retry := make(chan *httptest.ResponseRecorder)
+	go func() { retry <- serveIdempotent(router, "/orders/checkout", "1", "a") }()
+	select {
+	case w := <-retry:
+		t.Fatalf("retry returned %d before the first request completed", w.Code)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+
+	original, replayed := <-first, <-retry
+	if calls := atomic.LoadInt32(&calls); calls != 1 {
+		t.Errorf("handler calls = %d, want 1", calls)
+	}
+	if replayed.Header().Get(IdempotentReplayedHeader) != "true" || replayed.Body.String() != original.Body.String() {
+		t.Errorf("retry = %d %q, want the replayed %q", replayed.Code, replayed.Body.String(), original.Body.String())
+	}
+	if original.Body.String() != "call 1" {
+		t.Errorf("first body = %q", original.Body.String())
+	}
+}
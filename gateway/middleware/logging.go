@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Context keys the upstream proxy records the call it made under
+const (
+	UpstreamKey       = "upstream.name"
+	UpstreamStatusKey = "upstream.status"
+)
+
+// AccessLog writes one structured log record per request
+func AccessLog(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		size := c.Writer.Size()
+		if size < 0 {
+			size = 0
+		}
+		attrs := []slog.Attr{
+			slog.String("request_id", GetRequestID(c)),
+			slog.String("method", c.Request.Method),
+			slog.String("route", c.FullPath()),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int("bytes", size),
+			slog.String("client_ip", c.ClientIP()),
+		}
+
+		if user, ok := CurrentUser(c); ok {
+			attrs = append(attrs, slog.String("user", user.Username))
+		}
+		if upstream := c.GetString(UpstreamKey); upstream != "" {
+			attrs = append(attrs,
+				slog.String("upstream", upstream),
+				slog.Int("upstream_status", c.GetInt(UpstreamStatusKey)),
+			)
+		}
+		if span := trace.SpanContextFromContext(c.Request.Context()); span.HasTraceID() {
+			attrs = append(attrs, slog.String("trace_id", span.TraceID().String()))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		logger.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
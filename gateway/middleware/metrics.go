@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"gateway/metrics"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MetricsMiddleware records request counts, latencies and in-flight requests
+// labelled by route template rather than raw path
+func MetricsMiddleware(m *metrics.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = metrics.UnmatchedRoute
+		}
+		method := c.Request.Method
+
+		inFlight := m.InFlight.WithLabelValues(route, method)
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		start := time.Now()
+		c.Next()
+
+		status := strconv.Itoa(c.Writer.Status())
+		m.Requests.WithLabelValues(route, method, status).Inc()
+		m.RequestDuration.WithLabelValues(route, method, status).Observe(time.Since(start).Seconds())
+	}
+}
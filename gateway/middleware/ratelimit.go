@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"context"
+	"gateway/config"
+	"gateway/models"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHeader identifies API clients for rate limiting
+const APIKeyHeader = "X-API-Key"
+
+// RateLimitResult is the outcome of taking a token from a bucket
+type RateLimitResult struct {
+	Allowed bool
+	// Remaining is the number of requests left in the current window
+	Remaining int
+	// RetryAfter is the time until the next request is allowed
+	RetryAfter time.Duration
+	// Reset is the time until the limit is fully restored
+	Reset time.Duration
+}
+
+// RateLimitStore keeps the state of rate limit buckets. Implementations backed
+// by a shared store allow several gateway instances to enforce common limits.
+type RateLimitStore interface {
+	// Take consumes one request from the bucket identified by key
+	Take(ctx context.Context, key string, limit config.RateLimit) (RateLimitResult, error)
+}
+
+// RateLimiter limits the request rate of clients per route
+type RateLimiter struct {
+	limits  []config.RateLimit
+	apiKeys map[string]bool
+	store   RateLimitStore
+}
+
+// NewRateLimiter creates a rate limiter for limits with state kept in store,
+// apiKeys are the keys api_key limits accept
+func NewRateLimiter(limits []config.RateLimit, apiKeys []string, store RateLimitStore) *RateLimiter {
+	sorted := append([]config.RateLimit(nil), limits...)
+	// The most specific route prefix wins
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].Route) > len(sorted[j].Route)
+	})
+	known := make(map[string]bool, len(apiKeys))
+	for _, key := range apiKeys {
+		known[key] = true
+	}
+	return &RateLimiter{limits: sorted, apiKeys: known, store: store}
+}
+
+// Middleware rejects requests of clients that exceeded the limit of the
+// matched route with 429 Too Many Requests
+func (l *RateLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, ok := l.limitFor(c.FullPath())
+		if !ok {
+			c.Next()
+			return
+		}
+
+		key := limit.Route + "|" + l.clientKey(c, limit.Key)
+		result, err := l.store.Take(c.Request.Context(), key, limit)
+		if err != nil {
+			// Fail open, an unavailable store must not take the gateway down
+			log.Printf("Rate limit store error: %v", err)
+			c.Next()
+			return
+		}
+
+		header := c.Writer.Header()
+		header.Set("X-RateLimit-Limit", strconv.Itoa(limit.Requests))
+		header.Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		header.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+		if !result.Allowed {
+			header.Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, models.ErrorResponse{Error: "Too many requests"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func (l *RateLimiter) limitFor(route string) (config.RateLimit, bool) {
+	if route == "" {
+		return config.RateLimit{}, false
+	}
+	for _, limit := range l.limits {
+		if strings.HasPrefix(route, limit.Route) {
+			return limit, true
+		}
+	}
+	return config.RateLimit{}, false
+}
+
+// clientKey identifies the client of the request. User and API key limits
+// fall back to the client IP for requests without a user id or a known key,
+// so that made up keys do not get fresh buckets.
+func (l *RateLimiter) clientKey(c *gin.Context, kind string) string {
+	switch kind {
+	case config.RateLimitByUser:
+		if user, ok := CurrentUser(c); ok && user.ID != "" {
+			return "user:" + user.ID
+		}
+	case config.RateLimitByAPIKey:
+		if key := c.GetHeader(APIKeyHeader); key != "" && l.apiKeys[key] {
+			return "key:" + key
+		}
+	}
+	// ClientIP honours X-Forwarded-For and X-Real-IP set by trusted proxies
+	return "ip:" + c.ClientIP()
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"gateway/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimiterClientKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter := NewRateLimiter(nil, []string{"partner-key"}, nil)
+	tests := []struct {
+		name   string
+		kind   string
+		user   *User
+		apiKey string
+		want   string
+	}{
+		{"ip", config.RateLimitByIP, &User{ID: "5"}, "partner-key", "ip:192.0.2.1"},
+		{"user by id", config.RateLimitByUser, &User{ID: "5", Username: "alice"}, "", "user:5"},
+		{"user without id", config.RateLimitByUser, &User{Username: "5"}, "", "ip:192.0.2.1"},
+		{"anonymous user", config.RateLimitByUser, nil, "", "ip:192.0.2.1"},
+		{"known api key", config.RateLimitByAPIKey, nil, "partner-key", "key:partner-key"},
+		{"unknown api key", config.RateLimitByAPIKey, nil, "random-1", "ip:192.0.2.1"},
+		{"missing api key", config.RateLimitByAPIKey, nil, "", "ip:192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/cart", nil)
+			c.Request.RemoteAddr = "192.0.2.1:1234"
+			if tt.apiKey != "" {
+				c.Request.Header.Set(APIKeyHeader, tt.apiKey)
+			}
+			if tt.user != nil {
+				c.Set(userKey, tt.user)
+			}
+
+			if got := limiter.clientKey(c, tt.kind); got != tt.want {
+				t.Errorf("clientKey(%s) = %q, want %q", tt.kind, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"gateway/config"
+	"gateway/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Authorize enforces the role requirements of the policy for the matched
+// route. It must run after Authenticate.
+func Authorize(policy *config.Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		required, ok := policy.RequiredRoles(c.Request.Method, c.FullPath())
+		if !ok {
+			c.Next()
+			return
+		}
+
+		user, ok := CurrentUser(c)
+		if !ok {
+			RequireAuth()(c)
+			return
+		}
+
+		if !hasAnyRole(policy.UserRoles(user.ID, user.Roles), required) {
+			c.AbortWithStatusJSON(http.StatusForbidden, models.ErrorResponse{Error: "Insufficient permissions"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func hasAnyRole(roles, required []string) bool {
+	for _, role := range roles {
+		for _, r := range required {
+			if role == r {
+				return true
+			}
+		}
+	}
+	return false
+}
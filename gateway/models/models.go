@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // UserCreate represents a user registration request
 type UserCreate struct {
 	FirstName string `json:"first_name" binding:"required" example:"John"`
@@ -47,10 +49,38 @@ type Product struct {
 	Photo            string  `json:"photo" example:"https://example.com/cake.jpg"`
 }
 
+// ProductPage represents a page of the product catalogue
+type ProductPage struct {
+	Items []Product `json:"items"`
+	// NextCursor fetches the next page, it is omitted on the last page
+	NextCursor string `json:"next_cursor,omitempty" example:"eyJvIjoyMCwiYSI6MjB9.rQ7TUSmn5FSHNbTePnhXhQ"`
+	// PrevCursor fetches the previous page, it is omitted on the first page
+	PrevCursor string `json:"prev_cursor,omitempty"`
+	// Total counts the products of all pages as reported by the product
+	// service when the page was read, null when it reported none
+	Total *int `json:"total" example:"42"`
+}
+
+// ProductSearchHit represents a product matching a search
+type ProductSearchHit struct {
+	Product
+	// Score is the relevance of the product for the query text
+	Score float64 `json:"score" example:"4.27"`
+}
+
+// ProductSearchResult represents a page of search results
+type ProductSearchResult struct {
+	Items []ProductSearchHit `json:"items"`
+	// NextCursor fetches the next page, it is omitted on the last page
+	NextCursor string `json:"next_cursor,omitempty" example:"eyJ2IjoyNS45OSwiaWQiOjQsInEiOiJhYjEyIn0"`
+	// Total counts the matching products of all pages
+	Total int `json:"total" example:"42"`
+}
+
 // CartItemCreate represents a cart item creation request
 type CartItemCreate struct {
-	ProductID int `json:"product_id" binding:"required" example:"1"`
-	Quantity  int `json:"quantity" binding:"required" example:"2"`
+	ProductID int `json:"product_id" binding:"min=1" minimum:"1" example:"1"`
+	Quantity  int `json:"quantity" binding:"min=1" minimum:"1" example:"2"`
 }
 
 // CartItem represents a cart item response
@@ -61,6 +91,36 @@ type CartItem struct {
 	Quantity  int `json:"quantity" example:"2"`
 }
 
+// Cart line statuses
+const (
+	CartLineAvailable   = "available"
+	CartLineNotFound    = "not_found"
+	CartLineUnavailable = "unavailable"
+)
+
+// CartLine represents a cart item with the details of its product
+type CartLine struct {
+	ID        int     `json:"id" example:"1"`
+	ProductID int     `json:"product_id" example:"1"`
+	Quantity  int     `json:"quantity" example:"2"`
+	Name      string  `json:"name,omitempty" example:"Chocolate Cake"`
+	Photo     string  `json:"photo,omitempty" example:"https://example.com/cake.jpg"`
+	UnitPrice float64 `json:"unit_price" example:"25.99"`
+	LineTotal float64 `json:"line_total" example:"51.98"`
+	// Status is available, not_found when the product no longer exists or
+	// unavailable when the product service could not be reached. Only
+	// available lines count towards the subtotal.
+	Status string `json:"status" example:"available"`
+}
+
+// DetailedCart represents the cart of a user with product details
+type DetailedCart struct {
+	Items    []CartLine `json:"items"`
+	Subtotal float64    `json:"subtotal" example:"51.98"`
+	// Complete is false when the details of some products are missing
+	Complete bool `json:"complete" example:"true"`
+}
+
 // MessageResponse represents a simple message response
 type MessageResponse struct {
 	Message string `json:"message" example:"Success"`
@@ -71,8 +131,28 @@ type ErrorResponse struct {
 	Error string `json:"error" example:"Error message"`
 }
 
+// FieldError describes an invalid field of a request body
+type FieldError struct {
+	Field   string `json:"field" example:"quantity"`
+	Message string `json:"message" example:"must be between 1 and 99"`
+}
+
+// ValidationErrorResponse represents a request body rejected by validation
+type ValidationErrorResponse struct {
+	Error  string       `json:"error" example:"Validation failed"`
+	Fields []FieldError `json:"fields"`
+}
+
 // VerifyResponse represents a verification response
 type VerifyResponse struct {
 	Exists bool `json:"exists" example:"true"`
 }
 
+// ConfigVersion represents the active gateway configuration
+type ConfigVersion struct {
+	Version  int                    `json:"version" example:"3"`
+	Checksum string                 `json:"checksum" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`
+	LoadedAt time.Time              `json:"loaded_at"`
+	Source   string                 `json:"source,omitempty" example:"/etc/gateway/config.yaml"`
+	Config   map[string]interface{} `json:"config,omitempty"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Order statuses
+const (
+	OrderPending   = "pending"
+	OrderPaid      = "paid"
+	OrderShipped   = "shipped"
+	OrderDelivered = "delivered"
+	OrderCancelled = "cancelled"
+)
+
+// OrderLine represents a product of an order with its price at checkout
+type OrderLine struct {
+	ProductID int     `json:"product_id" example:"1"`
+	Name      string  `json:"name" example:"Chocolate Cake"`
+	Photo     string  `json:"photo" example:"https://example.com/cake.jpg"`
+	UnitPrice float64 `json:"unit_price" example:"25.99"`
+	Quantity  int     `json:"quantity" example:"2"`
+	LineTotal float64 `json:"line_total" example:"51.98"`
+}
+
+// Order represents an order of a user
+type Order struct {
+	ID     int64  `json:"id" example:"1"`
+	UserID string `json:"user_id" example:"1"`
+	// Status is pending, paid, shipped, delivered or cancelled
+	Status    string      `json:"status" example:"pending"`
+	Lines     []OrderLine `json:"lines"`
+	Total     float64     `json:"total" example:"51.98"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// OrderStatusUpdate represents a request to change the status of an order
+type OrderStatusUpdate struct {
+	Status string `json:"status" binding:"required,oneof=paid shipped delivered cancelled" example:"shipped"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// Payment statuses
+const (
+	PaymentPending    = "pending"
+	PaymentProcessing = "processing"
+	PaymentSucceeded  = "succeeded"
+	PaymentDeclined   = "declined"
+	PaymentFailed     = "failed"
+	PaymentRefunded   = "refunded"
+)
+
+// Payment represents a charge for an order, the order lines are the snapshot
+// of the cart that is paid for
+type Payment struct {
+	ID      int64  `json:"id" example:"1"`
+	OrderID int64  `json:"order_id" example:"1"`
+	UserID  string `json:"user_id" example:"1"`
+	// Amount is charged in minor units of the currency, e.g. cents
+	Amount   int64  `json:"amount" example:"5198"`
+	Currency string `json:"currency" example:"USD"`
+	// Status is pending, processing, succeeded, declined, failed or refunded
+	Status string `json:"status" example:"succeeded"`
+	// IntentID identifies the payment at the provider
+	IntentID      string    `json:"intent_id" example:"fake_pi_1"`
+	FailureReason string    `json:"failure_reason,omitempty" example:"card_declined"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// PaymentCreate represents a request to pay for an order
+type PaymentCreate struct {
+	OrderID int64 `json:"order_id" binding:"min=1" minimum:"1" example:"1"`
+}
+
+// PaymentConfirm represents a request to charge a payment
+type PaymentConfirm struct {
+	// PaymentMethod is the provider token of the card or account to charge
+	PaymentMethod string `json:"payment_method" example:"fake_succeed"`
+}
@@ -0,0 +1,89 @@
+package orders
+
+import (
+	"context"
+	"gateway/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps orders in process memory, they are lost on restart
+type MemoryStore struct {
+	mu     sync.Mutex
+	orders map[int64]*models.Order
+	lastID int64
+	now    func() time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		orders: make(map[int64]*models.Order),
+		now:    time.Now,
+	}
+}
+
+// Create implements Store
+func (s *MemoryStore) Create(_ context.Context, order *models.Order) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	order.ID = s.lastID
+	order.CreatedAt = s.now().UTC()
+	order.UpdatedAt = order.CreatedAt
+	s.orders[order.ID] = clone(order)
+	return nil
+}
+
+// Get implements Store
+func (s *MemoryStore) Get(_ context.Context, id int64) (*models.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return clone(order), nil
+}
+
+// List implements Store
+func (s *MemoryStore) List(_ context.Context, userID string) ([]*models.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	orders := []*models.Order{}
+	for _, order := range s.orders {
+		if order.UserID == userID {
+			orders = append(orders, clone(order))
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].ID > orders[j].ID })
+	return orders, nil
+}
+
+// SetStatus implements Store
+func (s *MemoryStore) SetStatus(_ context.Context, id int64, status string) (*models.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if !CanTransition(order.Status, status) {
+		return nil, &TransitionError{From: order.Status, To: status}
+	}
+	order.Status = status
+	order.UpdatedAt = s.now().UTC()
+	return clone(order), nil
+}
+
+// clone copies the order so that callers cannot change stored ones
+func clone(order *models.Order) *models.Order {
+	copied := *order
+	copied.Lines = append([]models.OrderLine(nil), order.Lines...)
+	return &copied
+}
@@ -0,0 +1,205 @@
+package orders
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"gateway/models"
+
+	"github.com/lib/pq"
+)
+
+// schema creates the tables of the store if they do not exist yet
+const schema = `
+CREATE TABLE IF NOT EXISTS orders (
+	id         BIGSERIAL PRIMARY KEY,
+	user_id    TEXT NOT NULL,
+	status     TEXT NOT NULL,
+	total      DOUBLE PRECISION NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS orders_user_id_idx ON orders (user_id, id DESC);
+CREATE TABLE IF NOT EXISTS order_lines (
+	order_id   BIGINT NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+	position   INTEGER NOT NULL,
+	product_id INTEGER NOT NULL,
+	name       TEXT NOT NULL,
+	photo      TEXT NOT NULL,
+	unit_price DOUBLE PRECISION NOT NULL,
+	quantity   INTEGER NOT NULL,
+	line_total DOUBLE PRECISION NOT NULL,
+	PRIMARY KEY (order_id, position)
+);`
+
+// SQLStore keeps orders in a PostgreSQL database
+type SQLStore struct {
+	db *sql.DB
+}
+
+// OpenSQLStore connects to the PostgreSQL database at dsn and creates the
+// tables of the store
+func OpenSQLStore(ctx context.Context, dsn string) (*SQLStore, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create order tables: %w", err)
+	}
+	return &SQLStore{db: db}, nil
+}
+
+// Close closes the database connections
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+// Create implements Store
+func (s *SQLStore) Create(ctx context.Context, order *models.Order) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO orders (user_id, status, total) VALUES ($1, $2, $3) RETURNING id, created_at, updated_at`,
+		order.UserID, order.Status, order.Total,
+	).Scan(&order.ID, &order.CreatedAt, &order.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	for i, line := range order.Lines {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO order_lines (order_id, position, product_id, name, photo, unit_price, quantity, line_total)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			order.ID, i, line.ProductID, line.Name, line.Photo, line.UnitPrice, line.Quantity, line.LineTotal,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Get implements Store
+func (s *SQLStore) Get(ctx context.Context, id int64) (*models.Order, error) {
+	order, err := scanOrder(s.db.QueryRowContext(ctx,
+		`SELECT id, user_id, status, total, created_at, updated_at FROM orders WHERE id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.loadLines(ctx, []*models.Order{order}); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// List implements Store
+func (s *SQLStore) List(ctx context.Context, userID string) ([]*models.Order, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, user_id, status, total, created_at, updated_at FROM orders WHERE user_id = $1 ORDER BY id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := []*models.Order{}
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := s.loadLines(ctx, orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// SetStatus implements Store. The order row is locked while the transition
+// is checked so that concurrent changes are applied one after the other.
+func (s *SQLStore) SetStatus(ctx context.Context, id int64, status string) (*models.Order, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var current string
+	err = tx.QueryRowContext(ctx, `SELECT status FROM orders WHERE id = $1 FOR UPDATE`, id).Scan(&current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !CanTransition(current, status) {
+		return nil, &TransitionError{From: current, To: status}
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE orders SET status = $1, updated_at = now() WHERE id = $2`, status, id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+// loadLines fills in the lines of the orders
+func (s *SQLStore) loadLines(ctx context.Context, orders []*models.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+	byID := make(map[int64]*models.Order, len(orders))
+	ids := make([]int64, 0, len(orders))
+	for _, order := range orders {
+		order.Lines = []models.OrderLine{}
+		byID[order.ID] = order
+		ids = append(ids, order.ID)
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT order_id, product_id, name, photo, unit_price, quantity, line_total
+		FROM order_lines WHERE order_id = ANY($1) ORDER BY order_id, position`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var orderID int64
+		var line models.OrderLine
+		if err := rows.Scan(&orderID, &line.ProductID, &line.Name, &line.Photo, &line.UnitPrice, &line.Quantity, &line.LineTotal); err != nil {
+			return err
+		}
+		order := byID[orderID]
+		order.Lines = append(order.Lines, line)
+	}
+	return rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanOrder(row scanner) (*models.Order, error) {
+	var order models.Order
+	err := row.Scan(&order.ID, &order.UserID, &order.Status, &order.Total, &order.CreatedAt, &order.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
@@ -0,0 +1,53 @@
+// Package orders stores the orders placed through the gateway and enforces
+// their status transitions.
+package orders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gateway/models"
+)
+
+// ErrNotFound is returned for orders that do not exist
+var ErrNotFound = errors.New("order not found")
+
+// transitions are the statuses an order may change to from its current one
+var transitions = map[string][]string{
+	models.OrderPending: {models.OrderPaid, models.OrderCancelled},
+	models.OrderPaid:    {models.OrderShipped, models.OrderCancelled},
+	models.OrderShipped: {models.OrderDelivered},
+}
+
+// TransitionError is returned for status changes the order does not allow
+type TransitionError struct {
+	From, To string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("cannot change order status from %s to %s", e.From, e.To)
+}
+
+// CanTransition reports whether an order may change from status from to status to
+func CanTransition(from, to string) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Store keeps orders. Implementations must apply status changes atomically
+// so that concurrent changes cannot skip a transition.
+type Store interface {
+	// Create saves a new order and sets its id and timestamps
+	Create(ctx context.Context, order *models.Order) error
+	// Get returns the order with id or ErrNotFound
+	Get(ctx context.Context, id int64) (*models.Order, error)
+	// List returns the orders of the user, newest first
+	List(ctx context.Context, userID string) ([]*models.Order, error)
+	// SetStatus changes the status of the order and returns it, changes the
+	// current status does not allow fail with a *TransitionError
+	SetStatus(ctx context.Context, id int64, status string) (*models.Order, error)
+}
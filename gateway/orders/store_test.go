@@ -0,0 +1,64 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"gateway/models"
+	"testing"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{models.OrderPending, models.OrderPaid, true},
+		{models.OrderPending, models.OrderCancelled, true},
+		{models.OrderPending, models.OrderShipped, false},
+		{models.OrderPaid, models.OrderShipped, true},
+		{models.OrderPaid, models.OrderCancelled, true},
+		{models.OrderPaid, models.OrderPending, false},
+		{models.OrderPaid, models.OrderPaid, false},
+		{models.OrderShipped, models.OrderDelivered, true},
+		{models.OrderShipped, models.OrderCancelled, false},
+		{models.OrderDelivered, models.OrderCancelled, false},
+		{models.OrderCancelled, models.OrderPaid, false},
+		{"unknown", models.OrderPaid, false},
+	}
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestMemoryStoreSetStatus(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	order := &models.Order{UserID: "1", Status: models.OrderPending}
+	if err := store.Create(ctx, order); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		status  string
+		wantErr bool
+	}{
+		{models.OrderShipped, true},
+		{models.OrderPaid, false},
+		{models.OrderShipped, false},
+		{models.OrderCancelled, true},
+		{models.OrderDelivered, false},
+	}
+	for _, step := range steps {
+		_, err := store.SetStatus(ctx, order.ID, step.status)
+		var transition *TransitionError
+		if step.wantErr != errors.As(err, &transition) {
+			t.Fatalf("SetStatus(%s) error = %v, want transition error %v", step.status, err, step.wantErr)
+		}
+	}
+
+	if _, err := store.SetStatus(ctx, order.ID+1, models.OrderPaid); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetStatus of a missing order error = %v, want ErrNotFound", err)
+	}
+}
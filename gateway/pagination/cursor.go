@@ -0,0 +1,65 @@
+// Package pagination implements the opaque cursors of the paginated
+// responses of the gateway and the paging of upstream lists behind them.
+package pagination
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// ErrInvalidCursor is returned for cursors that are malformed, were not
+// signed with the key of the gateway or were issued for another kind of list
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// macSize is the length of the truncated HMAC-SHA256 of a cursor
+const macSize = 16
+
+// Signer makes cursors opaque and tamper proof. A cursor is the JSON of its
+// value and a MAC over the value and the kind of list it was issued for, in
+// the form <base64url json>.<base64url mac>.
+type Signer struct {
+	key []byte
+}
+
+// NewSigner creates a signer with the key
+func NewSigner(key []byte) *Signer {
+	return &Signer{key: key}
+}
+
+// Encode returns the cursor of value for lists of the kind
+func (s *Signer) Encode(kind string, value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		panic(err)
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(s.sign(kind, payload))
+}
+
+// Decode verifies that the cursor was issued for lists of the kind and
+// unmarshals its value into value
+func (s *Signer) Decode(kind, cursor string, value interface{}) error {
+	payload, signature, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return ErrInvalidCursor
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, s.sign(kind, payload)) {
+		return ErrInvalidCursor
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || json.Unmarshal(data, value) != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+func (s *Signer) sign(kind, payload string) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(kind + "\x00" + payload))
+	return mac.Sum(nil)[:macSize]
+}
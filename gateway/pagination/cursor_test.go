@@ -0,0 +1,57 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSignerRoundTrip(t *testing.T) {
+	signer := NewSigner([]byte("secret"))
+	tests := []Position{
+		{},
+		{Offset: 20, Anchor: 21},
+		{Offset: 1 << 30, Anchor: 7},
+	}
+	for _, want := range tests {
+		cursor := signer.Encode("list", want)
+		var got Position
+		if err := signer.Decode("list", cursor, &got); err != nil {
+			t.Fatalf("Decode(Encode(%+v)): %v", want, err)
+		}
+		if got != want {
+			t.Errorf("Decode(Encode(%+v)) = %+v", want, got)
+		}
+	}
+}
+
+func TestSignerRejects(t *testing.T) {
+	signer := NewSigner([]byte("secret"))
+	valid := signer.Encode("list", Position{Offset: 20, Anchor: 21})
+	payload, mac, _ := strings.Cut(valid, ".")
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"o":200}`))
+
+	tests := []struct {
+		name   string
+		signer *Signer
+		kind   string
+		cursor string
+	}{
+		{"other kind", signer, "search", valid},
+		{"other key", NewSigner([]byte("other")), "list", valid},
+		{"forged payload", signer, "list", forged + "." + mac},
+		{"truncated mac", signer, "list", payload + "." + mac[:len(mac)-2]},
+		{"missing mac", signer, "list", payload},
+		{"empty", signer, "list", ""},
+		{"not base64", signer, "list", "!!!." + mac},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Position
+			if err := tt.signer.Decode(tt.kind, tt.cursor, &got); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("Decode() error = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package pagination
+
+import (
+	"errors"
+	"sort"
+)
+
+// ErrUnordered is returned for upstream lists that are not sorted by
+// ascending id, cursors into them would not be stable
+var ErrUnordered = errors.New("list is not ordered by ascending id")
+
+// Position is the place of a page in an upstream list ordered by ascending
+// id, the zero position is the first page
+type Position struct {
+	// Offset is the skip of the page in the upstream list
+	Offset int `json:"o"`
+	// Anchor is the id of the item before the page. Removing items before
+	// the page shifts the offset, the page is realigned behind the anchor.
+	Anchor int `json:"a,omitempty"`
+}
+
+// Window is the part of the upstream list read for a page. It starts one
+// page and an item before the position, for the anchor of the previous page
+// and the anchor of the page when items before it were removed, and ends one
+// item behind the page, to know whether more items follow.
+type Window struct {
+	Skip  int
+	Limit int
+}
+
+// NewWindow returns the window of the page of limit items at the position
+func NewWindow(at Position, limit int) Window {
+	skip := max(0, at.Offset-limit-1)
+	return Window{Skip: skip, Limit: at.Offset + limit + 1 - skip}
+}
+
+// Page is a page located in a window
+type Page struct {
+	// Start and End bound the items of the page in the window
+	Start, End int
+	// Offset is the skip of the first item of the page in the upstream list
+	Offset int
+	// Next and Prev are the positions of the neighbouring pages, nil when
+	// there is none
+	Next, Prev *Position
+}
+
+// Locate finds the page of limit items at the position in the window, ids
+// are the ids of the items read for the window
+func (w Window) Locate(at Position, limit int, ids []int) (Page, error) {
+	for i := 1; i < len(ids); i++ {
+		if ids[i] <= ids[i-1] {
+			return Page{}, ErrUnordered
+		}
+	}
+
+	start := min(at.Offset-w.Skip, len(ids))
+	if at.Anchor != 0 {
+		// The page starts behind the anchor wherever it moved to
+		start = sort.SearchInts(ids, at.Anchor+1)
+	}
+	end := min(start+limit, len(ids))
+	page := Page{Start: start, End: end, Offset: w.Skip + start}
+
+	// A full window may end before the items that follow the page
+	if end > start && (end < len(ids) || len(ids) == w.Limit) {
+		page.Next = &Position{Offset: page.Offset + end - start, Anchor: ids[end-1]}
+	}
+	if page.Offset > 0 {
+		prev := Position{Offset: max(0, page.Offset-limit)}
+		if i := prev.Offset - 1 - w.Skip; i >= 0 && i < len(ids) {
+			prev.Anchor = ids[i]
+		}
+		page.Prev = &prev
+	}
+	return page, nil
+}
@@ -0,0 +1,165 @@
+package pagination
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// span returns the ids from..to
+func span(from, to int) []int {
+	var ids []int
+	for id := from; id <= to; id++ {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// without returns the ids except the removed ones
+func without(ids []int, removed ...int) []int {
+	skip := make(map[int]bool)
+	for _, id := range removed {
+		skip[id] = true
+	}
+	var kept []int
+	for _, id := range ids {
+		if !skip[id] {
+			kept = append(kept, id)
+		}
+	}
+	return kept
+}
+
+func TestNewWindow(t *testing.T) {
+	tests := []struct {
+		at    Position
+		limit int
+		want  Window
+	}{
+		{Position{}, 10, Window{Skip: 0, Limit: 11}},
+		{Position{Offset: 5}, 10, Window{Skip: 0, Limit: 16}},
+		{Position{Offset: 30, Anchor: 30}, 10, Window{Skip: 19, Limit: 22}},
+	}
+	for _, tt := range tests {
+		if got := NewWindow(tt.at, tt.limit); got != tt.want {
+			t.Errorf("NewWindow(%+v, %d) = %+v, want %+v", tt.at, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestWindowLocate(t *testing.T) {
+	// catalogue returns the window of the position on a list of the ids
+	catalogue := func(all []int, at Position, limit int) (Window, []int) {
+		w := NewWindow(at, limit)
+		end := min(w.Skip+w.Limit, len(all))
+		if w.Skip >= end {
+			return w, nil
+		}
+		return w, all[w.Skip:end]
+	}
+
+	tests := []struct {
+		name     string
+		all      []int
+		at       Position
+		limit    int
+		wantIDs  []int
+		wantNext *Position
+		wantPrev *Position
+	}{
+		{
+			name:     "first page",
+			all:      span(1, 25),
+			limit:    10,
+			wantIDs:  span(1, 10),
+			wantNext: &Position{Offset: 10, Anchor: 10},
+		},
+		{
+			name:     "middle page",
+			all:      span(1, 25),
+			at:       Position{Offset: 10, Anchor: 10},
+			limit:    10,
+			wantIDs:  span(11, 20),
+			wantNext: &Position{Offset: 20, Anchor: 20},
+			wantPrev: &Position{Offset: 0},
+		},
+		{
+			name:     "last page",
+			all:      span(1, 25),
+			at:       Position{Offset: 20, Anchor: 20},
+			limit:    10,
+			wantIDs:  span(21, 25),
+			wantPrev: &Position{Offset: 10, Anchor: 10},
+		},
+		{
+			name:     "exactly full last page",
+			all:      span(1, 20),
+			at:       Position{Offset: 10, Anchor: 10},
+			limit:    10,
+			wantIDs:  span(11, 20),
+			wantPrev: &Position{Offset: 0},
+		},
+		{
+			name:     "items removed before the page",
+			all:      without(span(1, 30), 2, 3, 4, 5, 6),
+			at:       Position{Offset: 10, Anchor: 10},
+			limit:    10,
+			wantIDs:  span(11, 20),
+			wantNext: &Position{Offset: 15, Anchor: 20},
+			wantPrev: &Position{Offset: 0},
+		},
+		{
+			name:     "anchor removed",
+			all:      without(span(1, 30), 10),
+			at:       Position{Offset: 10, Anchor: 10},
+			limit:    10,
+			wantIDs:  span(11, 20),
+			wantNext: &Position{Offset: 19, Anchor: 20},
+			wantPrev: &Position{Offset: 0},
+		},
+		{
+			name:     "items added behind the list",
+			all:      span(1, 40),
+			at:       Position{Offset: 20, Anchor: 20},
+			limit:    10,
+			wantIDs:  span(21, 30),
+			wantNext: &Position{Offset: 30, Anchor: 30},
+			wantPrev: &Position{Offset: 10, Anchor: 10},
+		},
+		{
+			name:     "offset past the end",
+			all:      span(1, 5),
+			at:       Position{Offset: 50},
+			limit:    10,
+			wantIDs:  nil,
+			wantPrev: &Position{Offset: 29},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, ids := catalogue(tt.all, tt.at, tt.limit)
+			page, err := w.Locate(tt.at, tt.limit, ids)
+			if err != nil {
+				t.Fatalf("Locate() error = %v", err)
+			}
+			if got := ids[page.Start:page.End]; !reflect.DeepEqual(got, tt.wantIDs) {
+				t.Errorf("page ids = %v, want %v", got, tt.wantIDs)
+			}
+			if !reflect.DeepEqual(page.Next, tt.wantNext) {
+				t.Errorf("next = %+v, want %+v", page.Next, tt.wantNext)
+			}
+			if !reflect.DeepEqual(page.Prev, tt.wantPrev) {
+				t.Errorf("prev = %+v, want %+v", page.Prev, tt.wantPrev)
+			}
+		})
+	}
+}
+
+func TestWindowLocateUnordered(t *testing.T) {
+	w := NewWindow(Position{}, 10)
+	for _, ids := range [][]int{{1, 3, 2}, {1, 1, 2}} {
+		if _, err := w.Locate(Position{}, 10, ids); !errors.Is(err, ErrUnordered) {
+			t.Errorf("Locate(%v) error = %v, want ErrUnordered", ids, err)
+		}
+	}
+}
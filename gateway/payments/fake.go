@@ -0,0 +1,129 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Outcomes of fake charges
+const (
+	OutcomeSucceed = "succeed"
+	OutcomeDecline = "decline"
+	OutcomeTimeout = "timeout"
+)
+
+// fakeMethodPrefix selects the outcome of a single charge, e.g. the payment
+// method fake_decline is always declined
+const fakeMethodPrefix = "fake_"
+
+// Fake is a local provider that charges nothing. Charges succeed, are declined
+// or time out as chosen by the payment method or the default outcome, so
+// that checkout can be exercised without a real provider.
+type Fake struct {
+	secret string
+	now    func() time.Time
+
+	mu      sync.Mutex
+	outcome string
+	lastID  int
+	intents map[string]*fakeIntent
+}
+
+type fakeIntent struct {
+	amount   int64
+	currency string
+	charged  bool
+}
+
+// NewFake creates a fake provider with the default outcome of charges whose
+// webhooks are signed with secret
+func NewFake(outcome, secret string) *Fake {
+	return &Fake{
+		secret:  secret,
+		now:     time.Now,
+		outcome: outcome,
+		intents: make(map[string]*fakeIntent),
+	}
+}
+
+// SetOutcome changes the default outcome of charges
+func (f *Fake) SetOutcome(outcome string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.outcome = outcome
+}
+
+// CreateIntent implements Provider, intents are numbered fake_pi_1, fake_pi_2, ...
+func (f *Fake) CreateIntent(_ context.Context, _ string, amount int64, currency string) (*Intent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.lastID++
+	id := "fake_pi_" + strconv.Itoa(f.lastID)
+	f.intents[id] = &fakeIntent{amount: amount, currency: currency}
+	return &Intent{ID: id, Amount: amount, Currency: currency}, nil
+}
+
+// Confirm implements Provider. A payment method fake_<outcome> decides the
+// outcome of the charge, other payment methods get the default outcome.
+func (f *Fake) Confirm(_ context.Context, intentID, paymentMethod string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	intent, ok := f.intents[intentID]
+	if !ok {
+		return fmt.Errorf("unknown payment intent %q", intentID)
+	}
+	if intent.charged {
+		return nil
+	}
+
+	outcome := f.outcome
+	switch paymentMethod {
+	case fakeMethodPrefix + OutcomeSucceed, fakeMethodPrefix + OutcomeDecline, fakeMethodPrefix + OutcomeTimeout:
+		outcome = paymentMethod[len(fakeMethodPrefix):]
+	}
+
+	switch outcome {
+	case OutcomeDecline:
+		return ErrDeclined
+	case OutcomeTimeout:
+		return ErrTimeout
+	default:
+		intent.charged = true
+		return nil
+	}
+}
+
+// Refund implements Provider. Intents settled by a webhook after a timeout
+// can be refunded as well.
+func (f *Fake) Refund(_ context.Context, intentID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.intents[intentID]; !ok {
+		return fmt.Errorf("unknown payment intent %q", intentID)
+	}
+	return nil
+}
+
+// VerifyWebhook implements Provider, see Sign for the signature
+func (f *Fake) VerifyWebhook(header http.Header, payload []byte) (*Event, error) {
+	if err := VerifySignature(f.secret, header.Get(SignatureHeader), payload, f.now()); err != nil {
+		return nil, err
+	}
+
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("invalid webhook payload: %w", err)
+	}
+	if event.ID == "" || event.IntentID == "" {
+		return nil, fmt.Errorf("invalid webhook payload: id and intent_id are required")
+	}
+	return &event, nil
+}
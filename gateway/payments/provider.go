@@ -0,0 +1,60 @@
+// Package payments charges customers for their orders through a payment
+// provider and records the state of each payment.
+package payments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+var (
+	// ErrDeclined is returned when the provider refuses to charge the payment method
+	ErrDeclined = errors.New("payment declined")
+	// ErrTimeout is returned when the provider did not answer in time, the
+	// outcome of the charge is then reported by a webhook
+	ErrTimeout = errors.New("payment provider timed out")
+	// ErrInvalidSignature is returned for webhooks that were not signed by the provider
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
+// Intent is a charge prepared at the provider
+type Intent struct {
+	ID       string
+	Amount   int64
+	Currency string
+}
+
+// Event types reported by provider webhooks
+const (
+	EventSucceeded = "payment.succeeded"
+	EventDeclined  = "payment.declined"
+	EventFailed    = "payment.failed"
+	EventRefunded  = "payment.refunded"
+)
+
+// Event is a verified webhook of the provider about an intent
+type Event struct {
+	// ID identifies the event, providers may deliver an event more than once
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	IntentID string `json:"intent_id"`
+	// Reason explains declined and failed payments
+	Reason string `json:"reason,omitempty"`
+}
+
+// Provider charges customers
+type Provider interface {
+	// CreateIntent prepares a charge of amount in minor units of currency,
+	// reference identifies the payment at the gateway
+	CreateIntent(ctx context.Context, reference string, amount int64, currency string) (*Intent, error)
+	// Confirm charges the payment method for the intent. It fails with
+	// ErrDeclined when the charge is refused and ErrTimeout when its outcome
+	// is not known yet.
+	Confirm(ctx context.Context, intentID, paymentMethod string) error
+	// Refund returns the charged amount of the intent to the customer
+	Refund(ctx context.Context, intentID string) error
+	// VerifyWebhook checks that the webhook was sent by the provider and
+	// returns its event, forged webhooks fail with ErrInvalidSignature
+	VerifyWebhook(header http.Header, payload []byte) (*Event, error)
+}
@@ -0,0 +1,228 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gateway/models"
+	"gateway/orders"
+	"log"
+	"math"
+	"time"
+)
+
+// Service charges orders through the provider. Successful payments mark
+// their order paid, refunds cancel it.
+type Service struct {
+	provider Provider
+	store    Store
+	orders   orders.Store
+	currency string
+	timeout  time.Duration
+}
+
+// NewService creates a service charging in currency that waits up to
+// timeout for the provider
+func NewService(provider Provider, store Store, orders orders.Store, currency string, timeout time.Duration) *Service {
+	return &Service{provider: provider, store: store, orders: orders, currency: currency, timeout: timeout}
+}
+
+// Get returns the payment with id or ErrNotFound
+func (s *Service) Get(ctx context.Context, id int64) (*models.Payment, error) {
+	return s.store.Get(ctx, id)
+}
+
+// FindOpen returns the open payment of the order or ErrNotFound
+func (s *Service) FindOpen(ctx context.Context, orderID int64) (*models.Payment, error) {
+	return s.store.FindOpen(ctx, orderID)
+}
+
+// Start prepares the charge of the order total. An order is charged at most
+// once, the open payment of the order is returned when there is one and
+// created reports whether the payment is new. Orders that are no longer
+// pending fail with an *orders.TransitionError.
+func (s *Service) Start(ctx context.Context, order *models.Order) (payment *models.Payment, created bool, err error) {
+	if payment, err := s.store.FindOpen(ctx, order.ID); !errors.Is(err, ErrNotFound) {
+		return payment, false, err
+	}
+	if !orders.CanTransition(order.Status, models.OrderPaid) {
+		return nil, false, &orders.TransitionError{From: order.Status, To: models.OrderPaid}
+	}
+
+	amount := int64(math.Round(order.Total * 100))
+	reference := fmt.Sprintf("order-%d", order.ID)
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	intent, err := s.provider.CreateIntent(ctx, reference, amount, s.currency)
+	if err != nil {
+		return nil, false, err
+	}
+
+	payment = &models.Payment{
+		OrderID:  order.ID,
+		UserID:   order.UserID,
+		Amount:   intent.Amount,
+		Currency: intent.Currency,
+		Status:   models.PaymentPending,
+		IntentID: intent.ID,
+	}
+	err = s.store.Create(ctx, payment)
+	if errors.Is(err, ErrOpenPayment) {
+		// A concurrent request won, its intent is used and this one never charged
+		payment, err = s.store.FindOpen(ctx, order.ID)
+		return payment, false, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return payment, true, nil
+}
+
+// Confirm charges the payment method. Confirming a succeeded payment again
+// returns it unchanged. A payment whose outcome is not known when the
+// provider times out is left processing for a webhook to settle.
+func (s *Service) Confirm(ctx context.Context, payment *models.Payment, paymentMethod string) (*models.Payment, error) {
+	switch payment.Status {
+	case models.PaymentPending, models.PaymentProcessing:
+	case models.PaymentSucceeded:
+		return payment, nil
+	default:
+		return nil, &TransitionError{From: payment.Status, To: models.PaymentSucceeded}
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	err := s.provider.Confirm(callCtx, payment.IntentID, paymentMethod)
+	switch {
+	case err == nil:
+		return s.settle(ctx, payment.ID, models.PaymentSucceeded, "")
+	case errors.Is(err, ErrDeclined):
+		return s.settle(ctx, payment.ID, models.PaymentDeclined, "card_declined")
+	case errors.Is(err, ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return s.settle(ctx, payment.ID, models.PaymentProcessing, "")
+	default:
+		return nil, err
+	}
+}
+
+// Refund returns a succeeded payment to the customer, refunding it again
+// returns it unchanged
+func (s *Service) Refund(ctx context.Context, payment *models.Payment) (*models.Payment, error) {
+	switch payment.Status {
+	case models.PaymentSucceeded:
+	case models.PaymentRefunded:
+		return payment, nil
+	default:
+		return nil, &TransitionError{From: payment.Status, To: models.PaymentRefunded}
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	if err := s.provider.Refund(callCtx, payment.IntentID); err != nil {
+		return nil, err
+	}
+	return s.settle(ctx, payment.ID, models.PaymentRefunded, "")
+}
+
+// CancelOrder cancels the order. A paid order is cancelled by refunding its
+// succeeded payment, it fails with ErrNotFound when there is none and with a
+// *TransitionError when the payment is not settled yet. Orders that cannot
+// be cancelled fail with an *orders.TransitionError.
+func (s *Service) CancelOrder(ctx context.Context, order *models.Order) (*models.Order, error) {
+	if order.Status != models.OrderPaid {
+		return s.orders.SetStatus(ctx, order.ID, models.OrderCancelled)
+	}
+
+	payment, err := s.store.FindOpen(ctx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Status != models.PaymentSucceeded {
+		return nil, &TransitionError{From: payment.Status, To: models.PaymentRefunded}
+	}
+	if _, err := s.Refund(ctx, payment); err != nil {
+		return nil, err
+	}
+
+	// The refund cancels the order unless updating the order failed
+	cancelled, err := s.orders.Get(ctx, order.ID)
+	if err == nil && cancelled.Status == models.OrderPaid {
+		cancelled, err = s.orders.SetStatus(ctx, order.ID, models.OrderCancelled)
+	}
+	return cancelled, err
+}
+
+// HandleEvent applies a verified webhook of the provider. Redelivered events
+// and events of unknown intents are ignored, events that failed to apply are
+// applied when the provider retries them.
+func (s *Service) HandleEvent(ctx context.Context, event *Event) error {
+	status, ok := map[string]string{
+		EventSucceeded: models.PaymentSucceeded,
+		EventDeclined:  models.PaymentDeclined,
+		EventFailed:    models.PaymentFailed,
+		EventRefunded:  models.PaymentRefunded,
+	}[event.Type]
+	if !ok {
+		return nil
+	}
+	if seen, err := s.store.SeenEvent(ctx, event.ID); err != nil || seen {
+		return err
+	}
+
+	payment, err := s.store.FindByIntent(ctx, event.IntentID)
+	if errors.Is(err, ErrNotFound) {
+		log.Printf("Ignoring payment webhook %s of unknown intent %s", event.ID, event.IntentID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = s.settle(ctx, payment.ID, status, event.Reason)
+	var transition *TransitionError
+	if errors.As(err, &transition) {
+		// Events may arrive out of order, a payment that moved on keeps its status
+		log.Printf("Ignoring payment webhook %s: %v", event.ID, err)
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.store.RecordEvent(ctx, event.ID)
+}
+
+// settle changes the status of the payment and, when it changed, the status
+// of its order. A payment that succeeds for an order that can no longer be
+// paid, e.g. because it was cancelled meanwhile, is refunded.
+func (s *Service) settle(ctx context.Context, id int64, status, reason string) (*models.Payment, error) {
+	payment, changed, err := s.store.Transition(ctx, id, status, reason)
+	if err != nil || !changed {
+		return payment, err
+	}
+
+	var orderStatus string
+	switch status {
+	case models.PaymentSucceeded:
+		orderStatus = models.OrderPaid
+	case models.PaymentRefunded:
+		orderStatus = models.OrderCancelled
+	default:
+		return payment, nil
+	}
+
+	_, err = s.orders.SetStatus(ctx, payment.OrderID, orderStatus)
+	var transition *orders.TransitionError
+	switch {
+	case err == nil:
+		return payment, nil
+	case errors.As(err, &transition) && status == models.PaymentSucceeded:
+		log.Printf("Refunding payment %d: %v", payment.ID, err)
+		return s.Refund(ctx, payment)
+	case errors.As(err, &transition):
+		// Refunds of shipped or already cancelled orders leave the order as it is
+		return payment, nil
+	default:
+		log.Printf("Failed to update order %d of payment %d: %v", payment.OrderID, payment.ID, err)
+		return payment, nil
+	}
+}
@@ -0,0 +1,134 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"gateway/models"
+	"gateway/orders"
+	"testing"
+	"time"
+)
+
+// newTestService returns a service on the fake provider and memory stores
+// with an order in the status and a payment of it in the payment status
+func newTestService(t *testing.T, orderStatus, paymentStatus string) (*Service, *models.Order, *models.Payment) {
+	t.Helper()
+	ctx := context.Background()
+	orderStore := orders.NewMemoryStore()
+	store := NewMemoryStore()
+	provider := NewFake(OutcomeSucceed, "secret")
+	service := NewService(provider, store, orderStore, "USD", time.Second)
+
+	order := &models.Order{UserID: "1", Status: orderStatus, Total: 12.5}
+	if err := orderStore.Create(ctx, order); err != nil {
+		t.Fatal(err)
+	}
+	intent, err := provider.CreateIntent(ctx, "order", 1250, "USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment := &models.Payment{OrderID: order.ID, UserID: "1", Amount: 1250, Currency: "USD", Status: paymentStatus, IntentID: intent.ID}
+	if err := store.Create(ctx, payment); err != nil {
+		t.Fatal(err)
+	}
+	return service, order, payment
+}
+
+func TestSettle(t *testing.T) {
+	tests := []struct {
+		name          string
+		orderStatus   string
+		paymentStatus string
+		settle        string
+		wantPayment   string
+		wantOrder     string
+		wantErr       bool
+	}{
+		{"success pays the order", models.OrderPending, models.PaymentPending, models.PaymentSucceeded, models.PaymentSucceeded, models.OrderPaid, false},
+		{"late success processing", models.OrderPending, models.PaymentProcessing, models.PaymentSucceeded, models.PaymentSucceeded, models.OrderPaid, false},
+		{"decline keeps the order", models.OrderPending, models.PaymentPending, models.PaymentDeclined, models.PaymentDeclined, models.OrderPending, false},
+		{"timeout keeps the order", models.OrderPending, models.PaymentPending, models.PaymentProcessing, models.PaymentProcessing, models.OrderPending, false},
+		{"success of a cancelled order is refunded", models.OrderCancelled, models.PaymentProcessing, models.PaymentSucceeded, models.PaymentRefunded, models.OrderCancelled, false},
+		{"refund cancels the order", models.OrderPaid, models.PaymentSucceeded, models.PaymentRefunded, models.PaymentRefunded, models.OrderCancelled, false},
+		{"refund keeps a shipped order", models.OrderShipped, models.PaymentSucceeded, models.PaymentRefunded, models.PaymentRefunded, models.OrderShipped, false},
+		{"same status is a no-op", models.OrderPaid, models.PaymentSucceeded, models.PaymentSucceeded, models.PaymentSucceeded, models.OrderPaid, false},
+		{"declined cannot succeed", models.OrderPending, models.PaymentDeclined, models.PaymentSucceeded, models.PaymentDeclined, models.OrderPending, true},
+		{"pending cannot be refunded", models.OrderPending, models.PaymentPending, models.PaymentRefunded, models.PaymentPending, models.OrderPending, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			service, order, payment := newTestService(t, tt.orderStatus, tt.paymentStatus)
+
+			_, err := service.settle(ctx, payment.ID, tt.settle, "")
+			var transition *TransitionError
+			if tt.wantErr != errors.As(err, &transition) {
+				t.Fatalf("settle() error = %v, want transition error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("settle() error = %v", err)
+			}
+
+			stored, _ := service.Get(ctx, payment.ID)
+			if stored.Status != tt.wantPayment {
+				t.Errorf("payment status = %s, want %s", stored.Status, tt.wantPayment)
+			}
+			updated, _ := service.orders.Get(ctx, order.ID)
+			if updated.Status != tt.wantOrder {
+				t.Errorf("order status = %s, want %s", updated.Status, tt.wantOrder)
+			}
+		})
+	}
+}
+
+func TestCancelOrder(t *testing.T) {
+	tests := []struct {
+		name          string
+		orderStatus   string
+		paymentStatus string
+		wantPayment   string
+		wantOrder     string
+		wantErr       error
+	}{
+		{"pending order", models.OrderPending, models.PaymentPending, models.PaymentPending, models.OrderCancelled, nil},
+		{"paid order is refunded", models.OrderPaid, models.PaymentSucceeded, models.PaymentRefunded, models.OrderCancelled, nil},
+		{"paid order with unsettled payment", models.OrderPaid, models.PaymentProcessing, models.PaymentProcessing, models.OrderPaid, &TransitionError{}},
+		{"paid order without payment", models.OrderPaid, models.PaymentDeclined, models.PaymentDeclined, models.OrderPaid, ErrNotFound},
+		{"shipped order", models.OrderShipped, models.PaymentSucceeded, models.PaymentSucceeded, models.OrderShipped, &orders.TransitionError{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			service, order, payment := newTestService(t, tt.orderStatus, tt.paymentStatus)
+
+			_, err := service.CancelOrder(ctx, order)
+			switch want := tt.wantErr.(type) {
+			case nil:
+				if err != nil {
+					t.Fatalf("CancelOrder() error = %v", err)
+				}
+			case *TransitionError:
+				if !errors.As(err, &want) {
+					t.Fatalf("CancelOrder() error = %v, want payment transition error", err)
+				}
+			case *orders.TransitionError:
+				if !errors.As(err, &want) {
+					t.Fatalf("CancelOrder() error = %v, want order transition error", err)
+				}
+			default:
+				if !errors.Is(err, want) {
+					t.Fatalf("CancelOrder() error = %v, want %v", err, want)
+				}
+			}
+
+			stored, _ := service.Get(ctx, payment.ID)
+			if stored.Status != tt.wantPayment {
+				t.Errorf("payment status = %s, want %s", stored.Status, tt.wantPayment)
+			}
+			updated, _ := service.orders.Get(ctx, order.ID)
+			if updated.Status != tt.wantOrder {
+				t.Errorf("order status = %s, want %s", updated.Status, tt.wantOrder)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package payments
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"gateway/models"
+
+	"github.com/lib/pq"
+)
+
+// schema creates the tables of the store if they do not exist yet. The
+// partial index allows a single open payment per order.
+const schema = `
+CREATE TABLE IF NOT EXISTS payments (
+	id             BIGSERIAL PRIMARY KEY,
+	order_id       BIGINT NOT NULL,
+	user_id        TEXT NOT NULL,
+	amount         BIGINT NOT NULL,
+	currency       TEXT NOT NULL,
+	status         TEXT NOT NULL,
+	intent_id      TEXT NOT NULL,
+	failure_reason TEXT NOT NULL DEFAULT '',
+	created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE UNIQUE INDEX IF NOT EXISTS payments_intent_id_idx ON payments (intent_id);
+CREATE UNIQUE INDEX IF NOT EXISTS payments_open_order_idx ON payments (order_id)
+	WHERE status IN ('pending', 'processing', 'succeeded');
+CREATE TABLE IF NOT EXISTS payment_events (
+	id          TEXT PRIMARY KEY,
+	recorded_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);`
+
+// uniqueViolation is the PostgreSQL error code of unique index violations
+const uniqueViolation = "23505"
+
+// SQLStore keeps payments in a PostgreSQL database
+type SQLStore struct {
+	db *sql.DB
+}
+
+// OpenSQLStore connects to the PostgreSQL database at dsn and creates the
+// tables of the store
+func OpenSQLStore(ctx context.Context, dsn string) (*SQLStore, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create payment tables: %w", err)
+	}
+	return &SQLStore{db: db}, nil
+}
+
+// Close closes the database connections
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+// Create implements Store
+func (s *SQLStore) Create(ctx context.Context, payment *models.Payment) error {
+	err := s.db.QueryRowContext(ctx,
+		`INSERT INTO payments (order_id, user_id, amount, currency, status, intent_id, failure_reason)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at, updated_at`,
+		payment.OrderID, payment.UserID, payment.Amount, payment.Currency, payment.Status, payment.IntentID, payment.FailureReason,
+	).Scan(&payment.ID, &payment.CreatedAt, &payment.UpdatedAt)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation && pqErr.Constraint == "payments_open_order_idx" {
+		return ErrOpenPayment
+	}
+	return err
+}
+
+// Get implements Store
+func (s *SQLStore) Get(ctx context.Context, id int64) (*models.Payment, error) {
+	return scanPayment(s.db.QueryRowContext(ctx, selectPayment+` WHERE id = $1`, id))
+}
+
+// FindOpen implements Store
+func (s *SQLStore) FindOpen(ctx context.Context, orderID int64) (*models.Payment, error) {
+	return scanPayment(s.db.QueryRowContext(ctx,
+		selectPayment+` WHERE order_id = $1 AND status IN ($2, $3, $4)`,
+		orderID, models.PaymentPending, models.PaymentProcessing, models.PaymentSucceeded))
+}
+
+// FindByIntent implements Store
+func (s *SQLStore) FindByIntent(ctx context.Context, intentID string) (*models.Payment, error) {
+	return scanPayment(s.db.QueryRowContext(ctx, selectPayment+` WHERE intent_id = $1`, intentID))
+}
+
+// Transition implements Store. The payment row is locked while the
+// transition is checked so that concurrent changes are applied one after
+// the other.
+func (s *SQLStore) Transition(ctx context.Context, id int64, status, reason string) (*models.Payment, bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	payment, err := scanPayment(tx.QueryRowContext(ctx, selectPayment+` WHERE id = $1 FOR UPDATE`, id))
+	if err != nil {
+		return nil, false, err
+	}
+	if payment.Status == status {
+		return payment, false, nil
+	}
+	if !canTransition(payment.Status, status) {
+		return nil, false, &TransitionError{From: payment.Status, To: status}
+	}
+
+	payment, err = scanPayment(tx.QueryRowContext(ctx,
+		`UPDATE payments SET status = $1, failure_reason = $2, updated_at = now() WHERE id = $3
+		RETURNING id, order_id, user_id, amount, currency, status, intent_id, failure_reason, created_at, updated_at`,
+		status, reason, id))
+	if err != nil {
+		return nil, false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+	return payment, true, nil
+}
+
+// SeenEvent implements Store
+func (s *SQLStore) SeenEvent(ctx context.Context, eventID string) (bool, error) {
+	var seen bool
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM payment_events WHERE id = $1)`, eventID).Scan(&seen)
+	return seen, err
+}
+
+// RecordEvent implements Store
+func (s *SQLStore) RecordEvent(ctx context.Context, eventID string) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO payment_events (id) VALUES ($1) ON CONFLICT (id) DO NOTHING`, eventID)
+	return err
+}
+
+const selectPayment = `SELECT id, order_id, user_id, amount, currency, status, intent_id, failure_reason, created_at, updated_at FROM payments`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPayment(row scanner) (*models.Payment, error) {
+	var payment models.Payment
+	err := row.Scan(&payment.ID, &payment.OrderID, &payment.UserID, &payment.Amount, &payment.Currency,
+		&payment.Status, &payment.IntentID, &payment.FailureReason, &payment.CreatedAt, &payment.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
@@ -0,0 +1,195 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gateway/models"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned for payments that do not exist
+	ErrNotFound = errors.New("payment not found")
+	// ErrOpenPayment is returned when the order already has a payment that is
+	// not declined, failed or refunded
+	ErrOpenPayment = errors.New("order already has a payment")
+)
+
+// transitions are the statuses a payment may change to from its current one
+var transitions = map[string][]string{
+	models.PaymentPending:    {models.PaymentProcessing, models.PaymentSucceeded, models.PaymentDeclined, models.PaymentFailed},
+	models.PaymentProcessing: {models.PaymentSucceeded, models.PaymentDeclined, models.PaymentFailed},
+	models.PaymentSucceeded:  {models.PaymentRefunded},
+}
+
+// TransitionError is returned for status changes the payment does not allow
+type TransitionError struct {
+	From, To string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("cannot change payment status from %s to %s", e.From, e.To)
+}
+
+// open reports whether a payment with the status may still charge the order
+func open(status string) bool {
+	switch status {
+	case models.PaymentPending, models.PaymentProcessing, models.PaymentSucceeded:
+		return true
+	}
+	return false
+}
+
+// Store keeps payments
+type Store interface {
+	// Create saves a new payment and sets its id and timestamps. It fails
+	// with ErrOpenPayment when the order already has an open payment.
+	Create(ctx context.Context, payment *models.Payment) error
+	// Get returns the payment with id or ErrNotFound
+	Get(ctx context.Context, id int64) (*models.Payment, error)
+	// FindOpen returns the open payment of the order or ErrNotFound
+	FindOpen(ctx context.Context, orderID int64) (*models.Payment, error)
+	// FindByIntent returns the payment of the provider intent or ErrNotFound
+	FindByIntent(ctx context.Context, intentID string) (*models.Payment, error)
+	// Transition changes the status of the payment and reports whether it
+	// changed. Changing to the current status is a no-op so that retried
+	// confirmations and redelivered webhooks are harmless, other changes the
+	// status does not allow fail with a *TransitionError.
+	Transition(ctx context.Context, id int64, status, reason string) (*models.Payment, bool, error)
+	// SeenEvent reports whether the webhook event was recorded
+	SeenEvent(ctx context.Context, eventID string) (bool, error)
+	// RecordEvent remembers that the webhook event was applied
+	RecordEvent(ctx context.Context, eventID string) error
+}
+
+// MemoryStore keeps payments in process memory, they are lost on restart
+type MemoryStore struct {
+	mu       sync.Mutex
+	payments map[int64]*models.Payment
+	events   map[string]bool
+	lastID   int64
+	now      func() time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		payments: make(map[int64]*models.Payment),
+		events:   make(map[string]bool),
+		now:      time.Now,
+	}
+}
+
+// Create implements Store
+func (s *MemoryStore) Create(_ context.Context, payment *models.Payment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.findOpen(payment.OrderID) != nil {
+		return ErrOpenPayment
+	}
+	s.lastID++
+	payment.ID = s.lastID
+	payment.CreatedAt = s.now().UTC()
+	payment.UpdatedAt = payment.CreatedAt
+	copied := *payment
+	s.payments[payment.ID] = &copied
+	return nil
+}
+
+// Get implements Store
+func (s *MemoryStore) Get(_ context.Context, id int64) (*models.Payment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	payment, ok := s.payments[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *payment
+	return &copied, nil
+}
+
+// FindOpen implements Store
+func (s *MemoryStore) FindOpen(_ context.Context, orderID int64) (*models.Payment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	payment := s.findOpen(orderID)
+	if payment == nil {
+		return nil, ErrNotFound
+	}
+	copied := *payment
+	return &copied, nil
+}
+
+func (s *MemoryStore) findOpen(orderID int64) *models.Payment {
+	for _, payment := range s.payments {
+		if payment.OrderID == orderID && open(payment.Status) {
+			return payment
+		}
+	}
+	return nil
+}
+
+// FindByIntent implements Store
+func (s *MemoryStore) FindByIntent(_ context.Context, intentID string) (*models.Payment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, payment := range s.payments {
+		if payment.IntentID == intentID {
+			copied := *payment
+			return &copied, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Transition implements Store
+func (s *MemoryStore) Transition(_ context.Context, id int64, status, reason string) (*models.Payment, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	payment, ok := s.payments[id]
+	if !ok {
+		return nil, false, ErrNotFound
+	}
+	changed := payment.Status != status
+	if changed {
+		if !canTransition(payment.Status, status) {
+			return nil, false, &TransitionError{From: payment.Status, To: status}
+		}
+		payment.Status = status
+		payment.FailureReason = reason
+		payment.UpdatedAt = s.now().UTC()
+	}
+	copied := *payment
+	return &copied, changed, nil
+}
+
+// SeenEvent implements Store
+func (s *MemoryStore) SeenEvent(_ context.Context, eventID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.events[eventID], nil
+}
+
+// RecordEvent implements Store
+func (s *MemoryStore) RecordEvent(_ context.Context, eventID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events[eventID] = true
+	return nil
+}
+
+func canTransition(from, to string) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
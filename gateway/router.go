@@ -0,0 +1,448 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"gateway/config"
+	"gateway/discovery"
+	"gateway/handlers"
+	"gateway/health"
+	"gateway/metrics"
+	"gateway/middleware"
+	"gateway/orders"
+	"gateway/pagination"
+	"gateway/payments"
+	"gateway/search"
+	"gateway/tracing"
+	"gateway/transport"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+)
+
+// gateway serves requests with the router of the active configuration and
+// holds the state shared by all configurations
+type gateway struct {
+	logger         *slog.Logger
+	status         *health.Status
+	metrics        *metrics.Metrics
+	rateLimitStore middleware.RateLimitStore
+	idempotency    *middleware.IdempotencyKeys
+	orders         orders.Store
+	payments       *payments.Service
+	provider       payments.Provider
+	reloader       *config.Reloader
+	// cursorKey signs page cursors when the configuration sets no secret
+	cursorKey []byte
+
+	// clients of the active configuration are reused by the next one when
+	// their settings did not change so that connection pools, circuit
+	// breakers and health states survive a reload. Only apply touches them,
+	// the reloader serializes its calls.
+	clients map[string]*upstreamClient
+
+	router atomic.Pointer[gin.Engine]
+}
+
+// upstreamClient is the HTTP client of an upstream and the settings it was created with
+type upstreamClient struct {
+	settings clientSettings
+	client   *http.Client
+	balancer *transport.Balancer
+	watcher  *discovery.Watcher
+}
+
+type clientSettings struct {
+	upstream config.Upstream
+	breaker  config.Breaker
+	retry    config.Retry
+}
+
+// close stops the discovery and health checks of the client and releases
+// its idle connections, requests in flight keep using it
+func (u *upstreamClient) close() {
+	u.watcher.Stop()
+	u.balancer.Close()
+	u.client.CloseIdleConnections()
+}
+
+func newGateway(logger *slog.Logger, store orders.Store, service *payments.Service, provider payments.Provider) *gateway {
+	m := metrics.New()
+	cursorKey := make([]byte, 32)
+	if _, err := rand.Read(cursorKey); err != nil {
+		panic(err)
+	}
+	return &gateway{
+		logger:         logger,
+		status:         health.NewStatus(),
+		metrics:        m,
+		rateLimitStore: middleware.NewMemoryRateLimitStore(),
+		idempotency:    middleware.NewIdempotencyKeys(m),
+		orders:         store,
+		payments:       service,
+		provider:       provider,
+		cursorKey:      cursorKey,
+		clients:        make(map[string]*upstreamClient),
+	}
+}
+
+// ServeHTTP hands the request to the router active when it arrived
+func (g *gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.router.Load().ServeHTTP(w, r)
+}
+
+// apply builds the router of cfg and makes it serve new requests
+func (g *gateway) apply(cfg *config.Config) error {
+	clients := make(map[string]*upstreamClient, len(cfg.Upstreams))
+	router, err := g.newRouter(cfg, clients)
+	if err != nil {
+		closeUnused(clients, g.clients)
+		return err
+	}
+	g.router.Store(router)
+	closeUnused(g.clients, clients)
+	g.clients = clients
+	return nil
+}
+
+// newRouter creates the routes and middleware of the configuration, the
+// upstream clients it uses are added to clients
+func (g *gateway) newRouter(cfg *config.Config, clients map[string]*upstreamClient) (*gin.Engine, error) {
+	// Проверка access token'ов auth сервиса на стороне gateway
+	verifier, err := middleware.NewJWTVerifier(cfg.Auth.Algorithm, cfg.Auth.Secret, cfg.Auth.PublicKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("configure JWT verification: %w", err)
+	}
+
+	// Политика доступа к маршрутам по ролям
+	policy, err := config.LoadPolicy(cfg.PolicyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load access policy: %w", err)
+	}
+
+	// CORS политика с переопределениями для групп маршрутов
+	cors, err := middleware.NewCORS(cfg.CORS)
+	if err != nil {
+		return nil, fmt.Errorf("configure CORS: %w", err)
+	}
+
+	// Ограничение частоты запросов по маршрутам, счетчики сохраняются между версиями
+	rateLimiter := middleware.NewRateLimiter(cfg.RateLimits, cfg.APIKeys, g.rateLimitStore)
+
+	// Объединение одинаковых одновременных GET запросов анонимных клиентов
+	coalesced := append([]string(nil), cfg.Coalescing.Routes...)
+	for _, route := range cfg.Routes {
+		if route.Coalesce {
+			coalesced = append(coalesced, route.Path)
+		}
+	}
+	coalescer := middleware.NewCoalescer(coalesced, g.metrics)
+
+	router := gin.New()
+	router.Use(gin.Recovery())
+
+	// Адрес клиента берется из X-Forwarded-For/X-Real-IP только от доверенных прокси (nginx)
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("set trusted proxies: %w", err)
+	}
+
+	// Request id, трассировка, логи и метрики Prometheus
+	router.Use(middleware.RequestID())
+	router.Use(tracing.Middleware())
+	router.Use(middleware.AccessLog(g.logger))
+	router.Use(middleware.MetricsMiddleware(g.metrics))
+
+	// Добавляем CORS middleware
+	router.Use(cors.Middleware())
+	router.Use(middleware.Authenticate(verifier, cfg.Auth.UpstreamSecret))
+	router.Use(rateLimiter.Middleware())
+	router.Use(middleware.Authorize(policy))
+	// Повторы POST/PUT/DELETE с тем же Idempotency-Key получают сохраненный ответ.
+	// Webhook провайдер повторяет сам, события дедуплицируются по их id
+	if cfg.Idempotency.Enabled {
+		router.Use(g.idempotency.Middleware(cfg.Idempotency, "/webhooks/payments"))
+	}
+	router.Use(coalescer.Middleware())
+
+	// Создаем upstream'ы сервисов и проверки их готовности
+	var upstreams []*handlers.Upstream
+	var checks []health.Check
+	for _, name := range upstreamNames(cfg) {
+		upstream := cfg.Upstreams[name]
+		client, err := g.client(name, cfg, clients)
+		if err != nil {
+			return nil, err
+		}
+		// Балансировщик клиента подставляет адрес выбранного экземпляра
+		upstreams = append(upstreams, handlers.NewUpstream(name, "http://"+name, client.client))
+		if upstream.HealthPath != "" {
+			checks = append(checks, health.Check{
+				Name:     name,
+				URLs:     healthURLs(client.balancer, upstream.HealthPath),
+				Critical: upstream.Critical,
+			})
+		}
+	}
+	proxy := handlers.NewProxy(upstreams...)
+	authService, _ := proxy.Upstream("auth")
+	productService, _ := proxy.Upstream("product")
+	cartService, _ := proxy.Upstream("cart")
+
+	// Создаем handlers
+	authHandler := handlers.NewAuthHandler(authService)
+	// Кэш ответов каталога продуктов
+	var productCache *middleware.ResponseCache
+	var listCache, infoCache []gin.HandlerFunc
+	if cfg.Cache.Enabled {
+		productCache = middleware.NewResponseCache(cfg.Cache, g.metrics)
+		listCache = []gin.HandlerFunc{productCache.Middleware(middleware.QueryKey(map[string]int{"limit": cfg.Pagination.DefaultLimit}))}
+		infoCache = []gin.HandlerFunc{productCache.Middleware(middleware.PathKey)}
+	}
+	// Поисковый индекс каталога строится заново после перезагрузки конфигурации
+	searchIndex := search.NewIndex(search.NewMemoryBackend(), cfg.Search.RefreshInterval)
+	// Курсоры страниц подписываются общим секретом или ключом этого экземпляра
+	cursorKey := g.cursorKey
+	if cfg.Pagination.CursorSecret != "" {
+		cursorKey = []byte(cfg.Pagination.CursorSecret)
+	}
+	cursors := pagination.NewSigner(cursorKey)
+	productHandler := handlers.NewProductHandler(productService, productCache, searchIndex, cfg.Search, cursors, cfg.Pagination)
+	cartHandler := handlers.NewCartHandler(cartService, productService, cfg.Cart.MaxQuantity)
+	orderHandler := handlers.NewOrderHandler(g.orders, g.payments, cartService, productService)
+	paymentHandler := handlers.NewPaymentHandler(g.payments, g.provider, g.orders)
+	adminHandler := handlers.NewAdminHandler(g.reloader)
+
+	// Ошибки регистрации маршрутов возвращаются после сборки всех групп
+	var registerErr error
+	register := func(group *gin.RouterGroup, routes []handlers.Route) {
+		if err := proxy.Register(group, routes); err != nil && registerErr == nil {
+			registerErr = err
+		}
+	}
+
+	// Auth routes
+	authGroup := router.Group("/auth")
+	register(authGroup, []handlers.Route{
+		{Method: http.MethodPost, Path: "/register", Upstream: "auth", Handler: authHandler.Register},
+		{Method: http.MethodPost, Path: "/login", Upstream: "auth", Handler: authHandler.Login},
+		{Method: http.MethodPost, Path: "/logout", Upstream: "auth", Handler: authHandler.Logout},
+	})
+	register(authGroup.Group("", middleware.RequireAuth()), []handlers.Route{
+		{Method: http.MethodGet, Path: "/info", Upstream: "auth", Handler: authHandler.Info},
+	})
+
+	// Product routes
+	productGroup := router.Group("/product")
+	register(productGroup, []handlers.Route{
+		{Method: http.MethodGet, Path: "/list", Upstream: "product", Handler: productHandler.List, Middleware: listCache},
+		{Method: http.MethodGet, Path: "/search", Upstream: "product", Handler: productHandler.Search},
+		{Method: http.MethodGet, Path: "/verify/:name", Upstream: "product", Handler: productHandler.Verify},
+		{Method: http.MethodGet, Path: "/info/:id", Upstream: "product", Handler: productHandler.Info, Middleware: infoCache},
+	})
+	register(productGroup.Group("", middleware.RequireAuth()), []handlers.Route{
+		{Method: http.MethodPost, Path: "/add", Upstream: "product", Handler: productHandler.Add},
+		{Method: http.MethodPut, Path: "/update/:id", Upstream: "product", Handler: productHandler.Update},
+	})
+
+	// Cart routes
+	cartGroup := router.Group("/cart", middleware.RequireAuth(), middleware.RequireUserID())
+	register(cartGroup, []handlers.Route{
+		{Method: http.MethodGet, Path: "", Upstream: "cart", Handler: cartHandler.Get},
+		{Method: http.MethodGet, Path: "/detailed", Upstream: "cart", Handler: cartHandler.Detailed},
+		{Method: http.MethodPost, Path: "/add", Upstream: "cart", Handler: cartHandler.Add},
+		{Method: http.MethodPut, Path: "/update/:item_id", Upstream: "cart", Handler: cartHandler.Update},
+		{Method: http.MethodDelete, Path: "/delete/:item_id", Upstream: "cart", Handler: cartHandler.Delete},
+	})
+
+	// Заказы оформляются из корзины и хранятся в gateway
+	orderGroup := router.Group("/orders", middleware.RequireAuth(), middleware.RequireUserID())
+	orderGroup.GET("", orderHandler.List)
+	orderGroup.POST("/checkout", orderHandler.Checkout)
+	orderGroup.GET("/:id", orderHandler.Get)
+	orderGroup.POST("/:id/cancel", orderHandler.Cancel)
+
+	// Оплата заказов, исход платежа провайдер сообщает через подписанный webhook
+	paymentGroup := router.Group("/payments", middleware.RequireAuth(), middleware.RequireUserID())
+	paymentGroup.POST("", paymentHandler.Create)
+	paymentGroup.GET("/:id", paymentHandler.Get)
+	paymentGroup.POST("/:id/confirm", paymentHandler.Confirm)
+	router.POST("/webhooks/payments", paymentHandler.Webhook)
+
+	// Дополнительные маршруты из конфигурации
+	routes := make([]handlers.Route, 0, len(cfg.Routes))
+	for _, route := range cfg.Routes {
+		routes = append(routes, handlers.Route{Method: route.Method, Path: route.Path, Upstream: route.Upstream, Target: route.Target})
+	}
+	register(router.Group(""), routes)
+	if registerErr != nil {
+		return nil, fmt.Errorf("register routes: %w", registerErr)
+	}
+
+	// Активная версия конфигурации
+	adminGroup := router.Group("/admin", middleware.RequireAuth())
+	adminGroup.GET("/config", adminHandler.Config)
+	adminGroup.POST("/config/reload", adminHandler.Reload)
+	adminGroup.POST("/orders/:id/status", orderHandler.SetStatus)
+	adminGroup.POST("/payments/:id/refund", paymentHandler.Refund)
+
+	// Healthcheck
+	router.GET("/healthcheck", g.status.Healthcheck)
+
+	// Liveness и readiness с проверкой upstream сервисов
+	checker := health.NewChecker(g.status, checks, cfg.Readiness.Timeout, cfg.Readiness.CacheTTL)
+	router.GET("/livez", g.status.Livez)
+	router.GET("/readyz", checker.Readyz)
+
+	// Prometheus metrics
+	router.GET("/metrics", g.metrics.Handler())
+
+	// Swagger documentation
+	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+
+	return router, nil
+}
+
+// client returns the HTTP client of the upstream name and adds it to clients.
+// The active client is reused unless its settings changed.
+func (g *gateway) client(name string, cfg *config.Config, clients map[string]*upstreamClient) (*upstreamClient, error) {
+	upstream := *cfg.Upstreams[name]
+	// Readiness only, the client does not depend on it
+	upstream.Critical = false
+	settings := clientSettings{upstream: upstream, breaker: cfg.Breaker, retry: cfg.Retry}
+
+	if existing, ok := g.clients[name]; ok && reflect.DeepEqual(existing.settings, settings) {
+		clients[name] = existing
+		return existing, nil
+	}
+
+	provider, err := discoveryProvider(name, &upstream)
+	if err != nil {
+		return nil, err
+	}
+	balancer, err := transport.NewBalancer(name, balancerConfig(&upstream))
+	if err != nil {
+		return nil, err
+	}
+
+	// Экземпляры находятся до первого запроса и обновляются в фоне
+	watcher := discovery.NewWatcher(name, provider, upstream.Discovery.RetryInterval, func(endpoints []transport.Endpoint) {
+		if err := balancer.SetEndpoints(endpoints); err != nil {
+			g.logger.Error("Failed to update upstream instances", "upstream", name, "error", err)
+		}
+	})
+	watcher.Start()
+
+	client := transport.NewClient(name, transport.Config{
+		DialTimeout:           upstream.Transport.DialTimeout,
+		TLSHandshakeTimeout:   upstream.Transport.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: upstream.Transport.ResponseHeaderTimeout,
+		Timeout:               upstream.Transport.Timeout,
+		MaxIdleConnsPerHost:   upstream.Transport.MaxIdleConnsPerHost,
+		IdleConnTimeout:       upstream.Transport.IdleConnTimeout,
+		Breaker: transport.BreakerConfig{
+			FailureThreshold: cfg.Breaker.FailureThreshold,
+			OpenTimeout:      cfg.Breaker.OpenTimeout,
+		},
+		Retry: transport.RetryConfig{
+			Attempts:  cfg.Retry.Attempts,
+			BaseDelay: cfg.Retry.BaseDelay,
+			MaxDelay:  cfg.Retry.MaxDelay,
+		},
+		Balancer: balancer,
+		Middleware: []transport.Middleware{
+			tracing.InstrumentUpstream(name),
+			g.metrics.InstrumentUpstream(name),
+		},
+	})
+	clients[name] = &upstreamClient{settings: settings, client: client, balancer: balancer, watcher: watcher}
+	return clients[name], nil
+}
+
+// discoveryProvider returns the provider of the instances of the upstream
+func discoveryProvider(name string, upstream *config.Upstream) (discovery.Provider, error) {
+	d := upstream.Discovery
+	switch d.Provider {
+	case config.DiscoveryDNS:
+		provider, err := discovery.NewDNS(discovery.DNSConfig{
+			Name:       d.DNS.Name,
+			Record:     d.DNS.Record,
+			Scheme:     d.DNS.Scheme,
+			Port:       d.DNS.Port,
+			Server:     d.DNS.Server,
+			MinRefresh: d.DNS.MinRefresh,
+			MaxRefresh: d.DNS.MaxRefresh,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("upstream %s: %w", name, err)
+		}
+		return provider, nil
+	case config.DiscoveryFile:
+		return discovery.NewFile(d.File.Path, name, d.File.Interval), nil
+	default:
+		var endpoints discovery.Static
+		for _, instance := range upstream.Endpoints() {
+			endpoints = append(endpoints, transport.Endpoint{URL: instance.URL, Weight: instance.Weight})
+		}
+		return endpoints, nil
+	}
+}
+
+// healthURLs returns the health routes of the current instances of the balancer
+func healthURLs(balancer *transport.Balancer, path string) func() []string {
+	return func() []string {
+		endpoints := balancer.Endpoints()
+		urls := make([]string, 0, len(endpoints))
+		for _, endpoint := range endpoints {
+			urls = append(urls, strings.TrimRight(endpoint.URL, "/")+path)
+		}
+		return urls
+	}
+}
+
+// balancerConfig returns the load balancing settings of the upstream, its
+// instances are set by service discovery
+func balancerConfig(upstream *config.Upstream) transport.BalancerConfig {
+	balancer := transport.BalancerConfig{
+		Strategy:   upstream.Balancer,
+		HashHeader: upstream.HashHeader,
+		HealthCheck: transport.HealthCheckConfig{
+			Path:               upstream.HealthPath,
+			Interval:           upstream.HealthCheck.Interval,
+			Timeout:            upstream.HealthCheck.Timeout,
+			UnhealthyThreshold: upstream.HealthCheck.UnhealthyThreshold,
+			HealthyThreshold:   upstream.HealthCheck.HealthyThreshold,
+		},
+		Outlier: transport.OutlierConfig{
+			Consecutive5xx:     upstream.OutlierDetection.Consecutive5xx,
+			EjectionTime:       upstream.OutlierDetection.EjectionTime,
+			MaxEjectionPercent: upstream.OutlierDetection.MaxEjectionPercent,
+		},
+	}
+	return balancer
+}
+
+// closeUnused closes the clients that are not in use
+func closeUnused(clients, inUse map[string]*upstreamClient) {
+	for name, client := range clients {
+		if inUse[name] != client {
+			client.close()
+		}
+	}
+}
+
+// upstreamNames returns the configured upstream names in a stable order
+func upstreamNames(cfg *config.Config) []string {
+	names := make([]string, 0, len(cfg.Upstreams))
+	for name := range cfg.Upstreams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,56 @@
+package search
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"gateway/pagination"
+	"strconv"
+	"strings"
+)
+
+// Cursor is the position of the last hit of a page in the sort order
+type Cursor struct {
+	// Value is the score, price or weight of the hit
+	Value float64 `json:"v,omitempty"`
+	// Name is the name of the hit when sorting by name
+	Name string `json:"n,omitempty"`
+	ID   int    `json:"id"`
+	// Query identifies the query the cursor was issued for
+	Query string `json:"q"`
+}
+
+// EncodeCursor returns the signed cursor of the query with the signer
+func (q Query) EncodeCursor(signer *pagination.Signer, kind string, cursor *Cursor) string {
+	stamped := *cursor
+	stamped.Query = q.fingerprint()
+	return signer.Encode(kind, stamped)
+}
+
+// DecodeCursor verifies a cursor issued for the same query, it fails with
+// pagination.ErrInvalidCursor
+func (q Query) DecodeCursor(signer *pagination.Signer, kind, value string) (*Cursor, error) {
+	var cursor Cursor
+	if err := signer.Decode(kind, value, &cursor); err != nil {
+		return nil, err
+	}
+	if cursor.Query != q.fingerprint() {
+		return nil, pagination.ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// fingerprint identifies the text, filters and sort of the query
+func (q Query) fingerprint() string {
+	bound := func(value *float64) string {
+		if value == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*value, 'g', -1, 64)
+	}
+	parts := []string{
+		strings.Join(Tokenize(q.Text), " "), q.Sort,
+		bound(q.Price.Min), bound(q.Price.Max), bound(q.Weight.Min), bound(q.Weight.Max),
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,269 @@
+package search
+
+import (
+	"gateway/models"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// fieldWeights rank matches in the name above matches in descriptions
+var fieldWeights = [...]float64{
+	fieldName:             3,
+	fieldShortDescription: 2,
+	fieldComposition:      1.5,
+	fieldFullDescription:  1,
+}
+
+const (
+	fieldName = iota
+	fieldShortDescription
+	fieldComposition
+	fieldFullDescription
+	fieldCount
+)
+
+// prefixWeight discounts words that only start with a query word, so that
+// "choc" finds "chocolate" below products containing "choc" itself
+const prefixWeight = 0.5
+
+// minPrefixLength is the shortest query word matched as a prefix
+const minPrefixLength = 2
+
+// MemoryBackend is an inverted index of the products in process memory
+type MemoryBackend struct {
+	mu       sync.RWMutex
+	products map[int]models.Product
+	// postings holds the occurrences of each word per product and field
+	postings map[string]map[int]*[fieldCount]int
+	// words of each product, to remove it from postings
+	words map[int][]string
+}
+
+// NewMemoryBackend creates an empty index
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		products: make(map[int]models.Product),
+		postings: make(map[string]map[int]*[fieldCount]int),
+		words:    make(map[int][]string),
+	}
+}
+
+// Replace implements Backend
+func (b *MemoryBackend) Replace(products []models.Product) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.products = make(map[int]models.Product, len(products))
+	b.postings = make(map[string]map[int]*[fieldCount]int)
+	b.words = make(map[int][]string, len(products))
+	for _, product := range products {
+		b.add(product)
+	}
+}
+
+// Upsert implements Backend
+func (b *MemoryBackend) Upsert(product models.Product) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.remove(product.ID)
+	b.add(product)
+}
+
+// add indexes the product, the caller holds b.mu
+func (b *MemoryBackend) add(product models.Product) {
+	b.products[product.ID] = product
+	fields := [fieldCount]string{
+		fieldName:             product.Name,
+		fieldShortDescription: product.ShortDescription,
+		fieldComposition:      product.Composition,
+		fieldFullDescription:  product.FullDescription,
+	}
+	for field, text := range fields {
+		for _, word := range Tokenize(text) {
+			docs, ok := b.postings[word]
+			if !ok {
+				docs = make(map[int]*[fieldCount]int)
+				b.postings[word] = docs
+			}
+			counts, ok := docs[product.ID]
+			if !ok {
+				counts = new([fieldCount]int)
+				docs[product.ID] = counts
+				b.words[product.ID] = append(b.words[product.ID], word)
+			}
+			counts[field]++
+		}
+	}
+}
+
+// remove drops the product from the index, the caller holds b.mu
+func (b *MemoryBackend) remove(id int) {
+	for _, word := range b.words[id] {
+		delete(b.postings[word], id)
+		if len(b.postings[word]) == 0 {
+			delete(b.postings, word)
+		}
+	}
+	delete(b.words, id)
+	delete(b.products, id)
+}
+
+// Search implements Backend
+func (b *MemoryBackend) Search(query Query) Page {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var hits []Hit
+	words := Tokenize(query.Text)
+	scores := b.score(words)
+	for id, product := range b.products {
+		score, ok := scores[id]
+		if len(words) > 0 && !ok {
+			continue
+		}
+		if query.Price.Contains(product.Price) && query.Weight.Contains(product.Weight) {
+			hits = append(hits, Hit{Product: product, Score: score})
+		}
+	}
+
+	less := lessFunc(query.Sort)
+	sort.Slice(hits, func(i, j int) bool { return less(hits[i], hits[j]) })
+
+	page := Page{Total: len(hits)}
+	start := 0
+	if query.After != nil {
+		// The hits behind the position of the cursor, it may have been removed
+		after := query.After
+		position := Hit{
+			Product: models.Product{ID: after.ID, Name: after.Name, Price: after.Value, Weight: after.Value},
+			Score:   after.Value,
+		}
+		start = sort.Search(len(hits), func(i int) bool { return less(position, hits[i]) })
+	}
+	limit := query.Limit
+	if limit <= 0 {
+		limit = len(hits)
+	}
+	end := start + limit
+	if end < len(hits) {
+		page.Next = cursorOf(query.Sort, hits[end-1])
+	} else {
+		end = len(hits)
+	}
+	page.Hits = hits[start:end]
+	return page
+}
+
+// score rates the products containing every word, the caller holds b.mu
+func (b *MemoryBackend) score(words []string) map[int]float64 {
+	if len(words) == 0 {
+		return nil
+	}
+
+	var scores map[int]float64
+	total := float64(len(b.products))
+	for _, word := range words {
+		wordScores := make(map[int]float64)
+		for term, docs := range b.postings {
+			weight := 1.0
+			if term != word {
+				if utf8.RuneCountInString(word) < minPrefixLength || !strings.HasPrefix(term, word) {
+					continue
+				}
+				weight = prefixWeight
+			}
+			// Rare words count more than words most products contain
+			df := float64(len(docs))
+			idf := math.Log(1 + (total-df+0.5)/(df+0.5))
+			for id, counts := range docs {
+				var tf float64
+				for field, count := range counts {
+					if count > 0 {
+						tf += fieldWeights[field] * (1 + math.Log(float64(count)))
+					}
+				}
+				if s := weight * tf * idf; s > wordScores[id] {
+					wordScores[id] = s
+				}
+			}
+		}
+
+		if scores == nil {
+			scores = wordScores
+			continue
+		}
+		for id := range scores {
+			if s, ok := wordScores[id]; ok {
+				scores[id] += s
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+	return scores
+}
+
+// lessFunc orders hits by the sort, ties are broken by id
+func lessFunc(order string) func(a, b Hit) bool {
+	return func(a, b Hit) bool {
+		var cmp int
+		switch order {
+		case SortPriceAsc:
+			cmp = compareFloat(a.Product.Price, b.Product.Price)
+		case SortPriceDesc:
+			cmp = -compareFloat(a.Product.Price, b.Product.Price)
+		case SortWeightAsc:
+			cmp = compareFloat(a.Product.Weight, b.Product.Weight)
+		case SortWeightDesc:
+			cmp = -compareFloat(a.Product.Weight, b.Product.Weight)
+		case SortNameAsc:
+			cmp = strings.Compare(strings.ToLower(a.Product.Name), strings.ToLower(b.Product.Name))
+		case SortNameDesc:
+			cmp = -strings.Compare(strings.ToLower(a.Product.Name), strings.ToLower(b.Product.Name))
+		default:
+			cmp = -compareFloat(a.Score, b.Score)
+		}
+		if cmp != 0 {
+			return cmp < 0
+		}
+		return a.Product.ID < b.Product.ID
+	}
+}
+
+// cursorOf returns the position of the hit in the sort order
+func cursorOf(order string, hit Hit) *Cursor {
+	cursor := &Cursor{ID: hit.Product.ID}
+	switch order {
+	case SortPriceAsc, SortPriceDesc:
+		cursor.Value = hit.Product.Price
+	case SortWeightAsc, SortWeightDesc:
+		cursor.Value = hit.Product.Weight
+	case SortNameAsc, SortNameDesc:
+		cursor.Name = hit.Product.Name
+	default:
+		cursor.Value = hit.Score
+	}
+	return cursor
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Tokenize splits text into lower case words of letters and digits
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
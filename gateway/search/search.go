@@ -0,0 +1,183 @@
+// Package search keeps an in-process full-text index of the product
+// catalogue for the search endpoint of the gateway.
+package search
+
+import (
+	"context"
+	"gateway/models"
+	"log"
+	"sync"
+	"time"
+)
+
+// Sort orders of search results, relevance falls back to id order for
+// searches without text
+const (
+	SortRelevance  = "relevance"
+	SortPriceAsc   = "price"
+	SortPriceDesc  = "-price"
+	SortWeightAsc  = "weight"
+	SortWeightDesc = "-weight"
+	SortNameAsc    = "name"
+	SortNameDesc   = "-name"
+)
+
+// Sorts are the supported sort orders
+var Sorts = []string{SortRelevance, SortPriceAsc, SortPriceDesc, SortWeightAsc, SortWeightDesc, SortNameAsc, SortNameDesc}
+
+// Range bounds a numeric attribute, nil bounds are open
+type Range struct {
+	Min, Max *float64
+}
+
+// Contains reports whether value is within the range
+func (r Range) Contains(value float64) bool {
+	return (r.Min == nil || value >= *r.Min) && (r.Max == nil || value <= *r.Max)
+}
+
+// Query is a page of a search
+type Query struct {
+	// Text is matched against the name, descriptions and composition of
+	// products, every word must match. Empty text matches every product.
+	Text   string
+	Price  Range
+	Weight Range
+	Sort   string
+	// After continues the search behind the last hit of the previous page
+	After *Cursor
+	Limit int
+}
+
+// Hit is a matching product and its relevance for the query text
+type Hit struct {
+	Product models.Product
+	Score   float64
+}
+
+// Page is the result of a query
+type Page struct {
+	Hits []Hit
+	// Total counts all matching products, not just the ones of the page
+	Total int
+	// Next continues the search, nil on the last page
+	Next *Cursor
+}
+
+// Backend stores and searches products. Implementations must be safe for
+// concurrent use.
+type Backend interface {
+	// Replace drops every product and indexes the products
+	Replace(products []models.Product)
+	// Upsert adds the product or replaces the product with the same id
+	Upsert(product models.Product)
+	// Search returns a page of the products matching the query
+	Search(query Query) Page
+}
+
+// LoadFunc fetches the whole catalogue
+type LoadFunc func(ctx context.Context) ([]models.Product, error)
+
+// Index keeps a backend in sync with the product service. The catalogue is
+// loaded on first use and again once it is older than maxAge, changes made
+// through the gateway are applied immediately.
+type Index struct {
+	backend Backend
+	maxAge  time.Duration
+	now     func() time.Time
+
+	// mu serializes loads of the catalogue
+	mu    sync.Mutex
+	state sync.RWMutex
+	built bool
+	// loading is set while the catalogue is fetched, products upserted
+	// meanwhile are pending to be applied again on the loaded catalogue
+	loading bool
+	pending []models.Product
+	builtAt time.Time
+	// generation counts changes that outdate the loaded catalogue,
+	// builtGeneration is the generation it was loaded at
+	generation      uint64
+	builtGeneration uint64
+}
+
+// NewIndex creates an index on the backend that reloads the catalogue
+// after maxAge
+func NewIndex(backend Backend, maxAge time.Duration) *Index {
+	return &Index{backend: backend, maxAge: maxAge, now: time.Now}
+}
+
+// Search loads the catalogue when the index is missing or outdated and runs
+// the query. A failed reload keeps serving the products indexed before,
+// only an index that was never built fails the search.
+func (i *Index) Search(ctx context.Context, query Query, load LoadFunc) (Page, error) {
+	if !i.fresh() {
+		if err := i.refresh(ctx, load); err != nil {
+			i.state.RLock()
+			built := i.built
+			i.state.RUnlock()
+			if !built {
+				return Page{}, err
+			}
+			log.Printf("Failed to reload the catalogue, searching the previous one: %v", err)
+		}
+	}
+	return i.backend.Search(query), nil
+}
+
+// Upsert indexes a product added or updated through the gateway
+func (i *Index) Upsert(product models.Product) {
+	i.state.Lock()
+	defer i.state.Unlock()
+	i.backend.Upsert(product)
+	if i.loading {
+		// The catalogue being loaded may have been read before the change
+		i.pending = append(i.pending, product)
+	}
+}
+
+// Invalidate makes the next search reload the catalogue, for changes whose
+// product is not known
+func (i *Index) Invalidate() {
+	i.state.Lock()
+	defer i.state.Unlock()
+	i.generation++
+}
+
+func (i *Index) fresh() bool {
+	i.state.RLock()
+	defer i.state.RUnlock()
+	return i.built && i.builtGeneration == i.generation && i.now().Sub(i.builtAt) < i.maxAge
+}
+
+func (i *Index) refresh(ctx context.Context, load LoadFunc) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	// Another search may have loaded the catalogue while this one waited
+	if i.fresh() {
+		return nil
+	}
+
+	i.state.Lock()
+	i.loading = true
+	generation := i.generation
+	i.state.Unlock()
+	started := i.now()
+	products, err := load(ctx)
+
+	i.state.Lock()
+	defer i.state.Unlock()
+	pending := i.pending
+	i.loading = false
+	i.pending = nil
+	if err != nil {
+		return err
+	}
+	i.backend.Replace(products)
+	for _, product := range pending {
+		i.backend.Upsert(product)
+	}
+	i.built = true
+	i.builtAt = started
+	i.builtGeneration = generation
+	return nil
+}
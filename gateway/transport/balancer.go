@@ -0,0 +1,462 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Balancing strategies
+const (
+	RoundRobin       = "round_robin"
+	LeastConnections = "least_connections"
+	Weighted         = "weighted"
+	ConsistentHash   = "consistent_hash"
+)
+
+// ringReplicas is the number of points of an instance of weight 1 on the
+// consistent hash ring
+const ringReplicas = 100
+
+// Endpoint is an instance of an upstream service
+type Endpoint struct {
+	URL    string
+	Weight int
+}
+
+// HealthCheckConfig configures active probing of the instances
+type HealthCheckConfig struct {
+	// Path is probed on every instance, active checks are disabled without it
+	Path string
+	// Interval between probes, 0 disables active checks
+	Interval           time.Duration
+	Timeout            time.Duration
+	UnhealthyThreshold int
+	HealthyThreshold   int
+}
+
+// OutlierConfig configures ejection of instances failing live traffic
+type OutlierConfig struct {
+	// Consecutive5xx failures in a row eject an instance, 0 disables ejection
+	Consecutive5xx     int
+	EjectionTime       time.Duration
+	MaxEjectionPercent int
+}
+
+// BalancerConfig configures the instances of an upstream and how requests
+// are spread over them
+type BalancerConfig struct {
+	Strategy string
+	// HashHeader is the request header consistent hashing keys on
+	HashHeader  string
+	Endpoints   []Endpoint
+	HealthCheck HealthCheckConfig
+	Outlier     OutlierConfig
+}
+
+// ErrNoInstances is returned for calls to an upstream without known instances
+var ErrNoInstances = errors.New("no upstream instances available")
+
+// Balancer sends every attempt of an upstream call to one of its instances.
+// Instances failing active health checks or ejected by outlier detection are
+// skipped; when no instance is available all of them are used again rather
+// than failing every request.
+type Balancer struct {
+	name   string
+	config BalancerConfig
+	pool   atomic.Pointer[pool]
+
+	next atomic.Uint64
+	// mu guards the current weights of the weighted strategy and serializes
+	// changes of the pool
+	mu sync.Mutex
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type instance struct {
+	url    *url.URL
+	weight int
+
+	inFlight     atomic.Int64
+	healthy      atomic.Bool
+	ejectedUntil atomic.Int64
+	failures     atomic.Int32
+
+	// current is the smooth weighted round robin state, guarded by Balancer.mu
+	current int
+}
+
+// pool is an immutable set of instances
+type pool struct {
+	instances []*instance
+	ring      []ringPoint
+}
+
+type ringPoint struct {
+	hash     uint64
+	instance *instance
+}
+
+// NewBalancer creates the balancer of the upstream name and starts its active
+// health checks. Upstreams resolved by service discovery may start without
+// endpoints, see SetEndpoints.
+func NewBalancer(name string, config BalancerConfig) (*Balancer, error) {
+	b := &Balancer{
+		name:   name,
+		config: config,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if err := b.SetEndpoints(config.Endpoints); err != nil {
+		return nil, err
+	}
+
+	if config.HealthCheck.Interval > 0 && config.HealthCheck.Path != "" {
+		go b.healthCheck()
+	} else {
+		close(b.done)
+	}
+	return b, nil
+}
+
+// SetEndpoints replaces the instances of the upstream. Instances whose URL and
+// weight did not change keep their health state.
+func (b *Balancer) SetEndpoints(endpoints []Endpoint) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	existing := make(map[Endpoint]*instance)
+	if current := b.pool.Load(); current != nil {
+		for _, inst := range current.instances {
+			existing[inst.endpoint()] = inst
+		}
+	}
+
+	next := &pool{}
+	for _, endpoint := range endpoints {
+		if endpoint.Weight <= 0 {
+			endpoint.Weight = 1
+		}
+		inst, ok := existing[endpoint]
+		if !ok {
+			u, err := url.Parse(endpoint.URL)
+			if err != nil {
+				return fmt.Errorf("upstream %s: %w", b.name, err)
+			}
+			inst = &instance{url: u, weight: endpoint.Weight}
+			inst.healthy.Store(true)
+		}
+		next.instances = append(next.instances, inst)
+
+		for i := 0; i < ringReplicas*endpoint.Weight; i++ {
+			next.ring = append(next.ring, ringPoint{hash: hashKey(endpoint.URL + "#" + strconv.Itoa(i)), instance: inst})
+		}
+	}
+	sort.Slice(next.ring, func(i, j int) bool { return next.ring[i].hash < next.ring[j].hash })
+
+	b.pool.Store(next)
+	return nil
+}
+
+// Endpoints returns the current instances of the upstream
+func (b *Balancer) Endpoints() []Endpoint {
+	instances := b.pool.Load().instances
+	endpoints := make([]Endpoint, 0, len(instances))
+	for _, inst := range instances {
+		endpoints = append(endpoints, inst.endpoint())
+	}
+	return endpoints
+}
+
+// Close stops the active health checks
+func (b *Balancer) Close() {
+	select {
+	case <-b.stop:
+	default:
+		close(b.stop)
+	}
+	<-b.done
+}
+
+// Middleware returns a transport middleware that rewrites the scheme, host
+// and base path of every attempt to the chosen instance
+func (b *Balancer) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			inst, err := b.pick(req)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", b.name, err)
+			}
+
+			// RoundTrippers must not modify the request they are given
+			req = req.Clone(req.Context())
+			req.URL = inst.resolve(req.URL)
+			req.Host = ""
+
+			inst.inFlight.Add(1)
+			resp, err := next.RoundTrip(req)
+			b.observe(inst, req, resp, err)
+			if err != nil {
+				inst.inFlight.Add(-1)
+				return resp, err
+			}
+			resp.Body = &releaseBody{ReadCloser: resp.Body, release: func() { inst.inFlight.Add(-1) }}
+			return resp, nil
+		})
+	}
+}
+
+// pick chooses the instance for the request by the configured strategy
+func (b *Balancer) pick(req *http.Request) (*instance, error) {
+	p := b.pool.Load()
+	if len(p.instances) == 0 {
+		return nil, ErrNoInstances
+	}
+	candidates := p.available()
+
+	switch b.config.Strategy {
+	case LeastConnections:
+		return b.leastConnections(candidates), nil
+	case Weighted:
+		return b.weighted(candidates), nil
+	case ConsistentHash:
+		if key := req.Header.Get(b.config.HashHeader); key != "" {
+			return p.consistentHash(key, candidates), nil
+		}
+	}
+	return candidates[int(b.next.Add(1)-1)%len(candidates)], nil
+}
+
+// available returns the healthy instances that are not ejected, or all of
+// them when there are none
+func (p *pool) available() []*instance {
+	now := time.Now().UnixNano()
+	candidates := make([]*instance, 0, len(p.instances))
+	for _, inst := range p.instances {
+		if inst.healthy.Load() && inst.ejectedUntil.Load() <= now {
+			candidates = append(candidates, inst)
+		}
+	}
+	if len(candidates) == 0 {
+		return p.instances
+	}
+	return candidates
+}
+
+func (b *Balancer) leastConnections(candidates []*instance) *instance {
+	// Start at a rotating offset so that ties are spread evenly
+	offset := int(b.next.Add(1) - 1)
+	var best *instance
+	for i := range candidates {
+		inst := candidates[(offset+i)%len(candidates)]
+		if best == nil || inst.inFlight.Load()*int64(best.weight) < best.inFlight.Load()*int64(inst.weight) {
+			best = inst
+		}
+	}
+	return best
+}
+
+// weighted implements smooth weighted round robin
+func (b *Balancer) weighted(candidates []*instance) *instance {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	total := 0
+	var best *instance
+	for _, inst := range candidates {
+		inst.current += inst.weight
+		total += inst.weight
+		if best == nil || inst.current > best.current {
+			best = inst
+		}
+	}
+	best.current -= total
+	return best
+}
+
+// consistentHash returns the first available instance clockwise from key on the ring
+func (p *pool) consistentHash(key string, candidates []*instance) *instance {
+	allowed := make(map[*instance]bool, len(candidates))
+	for _, inst := range candidates {
+		allowed[inst] = true
+	}
+
+	hash := hashKey(key)
+	start := sort.Search(len(p.ring), func(i int) bool { return p.ring[i].hash >= hash })
+	for i := 0; i < len(p.ring); i++ {
+		point := p.ring[(start+i)%len(p.ring)]
+		if allowed[point.instance] {
+			return point.instance
+		}
+	}
+	return candidates[0]
+}
+
+// observe feeds the outcome of an attempt to outlier detection
+func (b *Balancer) observe(inst *instance, req *http.Request, resp *http.Response, err error) {
+	outlier := b.config.Outlier
+	if outlier.Consecutive5xx <= 0 {
+		return
+	}
+
+	var open *CircuitOpenError
+	failed := err != nil || resp.StatusCode >= http.StatusInternalServerError
+	if err != nil && (errors.As(err, &open) || errors.Is(req.Context().Err(), context.Canceled)) {
+		// Neither says anything about the instance
+		return
+	}
+	if !failed {
+		inst.failures.Store(0)
+		return
+	}
+	if inst.failures.Add(1) < int32(outlier.Consecutive5xx) {
+		return
+	}
+
+	inst.failures.Store(0)
+	now := time.Now()
+	instances := b.pool.Load().instances
+	ejected := 0
+	for _, other := range instances {
+		if other.ejectedUntil.Load() > now.UnixNano() {
+			ejected++
+		}
+	}
+	if (ejected+1)*100 > outlier.MaxEjectionPercent*len(instances) {
+		return
+	}
+	inst.ejectedUntil.Store(now.Add(outlier.EjectionTime).UnixNano())
+	log.Printf("Ejected instance %s of %s upstream for %s after %d consecutive failures", inst.url.Host, b.name, outlier.EjectionTime, outlier.Consecutive5xx)
+}
+
+// healthCheck probes every instance each interval until the balancer is closed
+func (b *Balancer) healthCheck() {
+	defer close(b.done)
+
+	check := b.config.HealthCheck
+	client := &http.Client{Timeout: check.Timeout}
+	ticker := time.NewTicker(check.Interval)
+	defer ticker.Stop()
+
+	// Consecutive probe results by instance, only this goroutine touches them
+	passes := make(map[*instance]int)
+	fails := make(map[*instance]int)
+
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+		}
+
+		instances := b.pool.Load().instances
+		var wg sync.WaitGroup
+		results := make([]bool, len(instances))
+		for i, inst := range instances {
+			wg.Add(1)
+			go func(i int, inst *instance) {
+				defer wg.Done()
+				results[i] = probe(client, inst.resolve(&url.URL{Path: check.Path}).String())
+			}(i, inst)
+		}
+		wg.Wait()
+
+		// Forget instances removed by service discovery
+		current := make(map[*instance]bool, len(instances))
+		for _, inst := range instances {
+			current[inst] = true
+		}
+		for inst := range fails {
+			if !current[inst] {
+				delete(passes, inst)
+				delete(fails, inst)
+			}
+		}
+
+		for i, inst := range instances {
+			if results[i] {
+				passes[inst], fails[inst] = passes[inst]+1, 0
+				if !inst.healthy.Load() && passes[inst] >= check.HealthyThreshold {
+					inst.healthy.Store(true)
+					log.Printf("Instance %s of %s upstream is healthy again", inst.url.Host, b.name)
+				}
+				continue
+			}
+			passes[inst], fails[inst] = 0, fails[inst]+1
+			if inst.healthy.Load() && fails[inst] >= check.UnhealthyThreshold {
+				inst.healthy.Store(false)
+				log.Printf("Instance %s of %s upstream failed %d health checks, taking it out of rotation", inst.url.Host, b.name, fails[inst])
+			}
+		}
+	}
+}
+
+// probe reports whether the health route at target answers with 2xx
+func probe(client *http.Client, target string) bool {
+	resp, err := client.Get(target)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices
+}
+
+func (inst *instance) endpoint() Endpoint {
+	return Endpoint{URL: inst.url.String(), Weight: inst.weight}
+}
+
+// resolve returns target with the scheme, host and base path of the instance
+func (inst *instance) resolve(target *url.URL) *url.URL {
+	resolved := *target
+	resolved.Scheme = inst.url.Scheme
+	resolved.Host = inst.url.Host
+	resolved.User = inst.url.User
+	if base := strings.TrimRight(inst.url.Path, "/"); base != "" {
+		resolved.Path = base + target.Path
+		if target.RawPath != "" {
+			resolved.RawPath = strings.TrimRight(inst.url.EscapedPath(), "/") + target.RawPath
+		}
+	}
+	return &resolved
+}
+
+// hashKey hashes key with FNV-1a followed by the MurmurHash3 finalizer, FNV
+// alone maps short keys such as user ids to a narrow range of the ring
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// releaseBody calls release once when the response body is closed
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (r *releaseBody) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
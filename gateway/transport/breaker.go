@@ -0,0 +1,187 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of a circuit breaker
+type BreakerState int
+
+const (
+	// StateClosed lets all requests through
+	StateClosed BreakerState = iota
+	// StateOpen rejects all requests until the open timeout passes
+	StateOpen
+	// StateHalfOpen lets a limited number of probe requests through
+	StateHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// BreakerConfig configures a circuit breaker
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures that opens the circuit
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before probing the upstream
+	OpenTimeout time.Duration
+	// HalfOpenRequests is the number of concurrent probes allowed when half-open
+	HalfOpenRequests int
+}
+
+// Outcome is the result of a request let through by a breaker
+type Outcome int
+
+const (
+	// OutcomeSuccess closes a half-open circuit and resets the failures
+	OutcomeSuccess Outcome = iota
+	// OutcomeFailure counts towards opening the circuit
+	OutcomeFailure
+	// OutcomeIgnored says nothing about the upstream, e.g. a request
+	// cancelled by the client, and only releases a half-open probe
+	OutcomeIgnored
+)
+
+// CircuitOpenError is returned for requests rejected by an open circuit
+type CircuitOpenError struct {
+	Upstream   string
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker for %s is open", e.Upstream)
+}
+
+// Breaker is a circuit breaker guarding a single upstream
+type Breaker struct {
+	name   string
+	config BreakerConfig
+	now    func() time.Time
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probes   int
+}
+
+// NewBreaker creates a closed circuit breaker for the upstream name
+func NewBreaker(name string, config BreakerConfig) *Breaker {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = 1
+	}
+	if config.HalfOpenRequests <= 0 {
+		config.HalfOpenRequests = 1
+	}
+	return &Breaker{name: name, config: config, now: time.Now}
+}
+
+// State returns the current state of the breaker
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+	return b.state
+}
+
+// Allow reports whether a request may be sent. The returned function must be
+// called with the outcome of the request.
+func (b *Breaker) Allow() (func(Outcome), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+
+	switch b.state {
+	case StateOpen:
+		return nil, &CircuitOpenError{
+			Upstream:   b.name,
+			RetryAfter: b.config.OpenTimeout - b.now().Sub(b.openedAt),
+		}
+	case StateHalfOpen:
+		if b.probes >= b.config.HalfOpenRequests {
+			return nil, &CircuitOpenError{Upstream: b.name, RetryAfter: time.Second}
+		}
+		b.probes++
+	}
+
+	state := b.state
+	return func(outcome Outcome) { b.record(state, outcome) }, nil
+}
+
+// advance moves an open circuit to half-open once the open timeout passed
+func (b *Breaker) advance() {
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.config.OpenTimeout {
+		b.state = StateHalfOpen
+		b.probes = 0
+	}
+}
+
+func (b *Breaker) record(state BreakerState, outcome Outcome) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if state == StateHalfOpen {
+		b.probes--
+	}
+	// Outcomes of requests started in an earlier state are stale
+	if state != b.state || outcome == OutcomeIgnored {
+		return
+	}
+
+	if outcome == OutcomeSuccess {
+		b.state = StateClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == StateHalfOpen || b.failures >= b.config.FailureThreshold {
+		b.state = StateOpen
+		b.openedAt = b.now()
+		b.failures = 0
+	}
+}
+
+// breakerTransport guards requests to an upstream with a circuit breaker
+type breakerTransport struct {
+	next    http.RoundTripper
+	breaker *Breaker
+}
+
+// WithBreaker wraps next with the circuit breaker. Transport errors and 5xx
+// responses count as failures, requests cancelled by the client do not count.
+func WithBreaker(next http.RoundTripper, breaker *Breaker) http.RoundTripper {
+	return &breakerTransport{next: next, breaker: breaker}
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	done, err := t.breaker.Allow()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	switch {
+	case err != nil && errors.Is(req.Context().Err(), context.Canceled):
+		done(OutcomeIgnored)
+	case err != nil || resp.StatusCode >= http.StatusInternalServerError:
+		done(OutcomeFailure)
+	default:
+		done(OutcomeSuccess)
+	}
+	return resp, err
+}
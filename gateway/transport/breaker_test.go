@@ -0,0 +1,74 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestBreakerFailureAccounting(t *testing.T) {
+	// Steps are responses of the upstream: a status code, "error" for a
+	// transport error, "cancel" for a request cancelled by the client or
+	// "wait" to let the open timeout pass
+	tests := []struct {
+		name  string
+		steps []string
+		want  BreakerState
+	}{
+		{"failures below the threshold", []string{"500", "error"}, StateClosed},
+		{"failures at the threshold", []string{"500", "error", "503"}, StateOpen},
+		{"client errors are successes", []string{"500", "404", "500", "400"}, StateClosed},
+		{"success resets the failures", []string{"500", "500", "200", "500", "500"}, StateClosed},
+		{"cancellations do not count", []string{"cancel", "cancel", "cancel", "cancel"}, StateClosed},
+		{"cancellations do not reset the failures", []string{"500", "500", "cancel", "500"}, StateOpen},
+		{"open circuit probes after the timeout", []string{"500", "500", "500", "wait"}, StateHalfOpen},
+		{"successful probe closes", []string{"500", "500", "500", "wait", "200"}, StateClosed},
+		{"failed probe opens", []string{"500", "500", "500", "wait", "500"}, StateOpen},
+		{"cancelled probe stays half-open", []string{"500", "500", "500", "wait", "cancel", "200"}, StateClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			breaker := NewBreaker("product", BreakerConfig{FailureThreshold: 3, OpenTimeout: time.Minute, HalfOpenRequests: 1})
+			breaker.now = func() time.Time { return now }
+
+			for i, step := range tt.steps {
+				if step == "wait" {
+					now = now.Add(time.Minute)
+					continue
+				}
+
+				ctx, cancel := context.WithCancel(context.Background())
+				upstream := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					switch step {
+					case "error":
+						return nil, errors.New("connection refused")
+					case "cancel":
+						cancel()
+						return nil, req.Context().Err()
+					}
+					status, _ := strconv.Atoi(step)
+					w := httptest.NewRecorder()
+					w.WriteHeader(status)
+					return w.Result(), nil
+				})
+
+				req := httptest.NewRequest(http.MethodGet, "/product/list", nil).WithContext(ctx)
+				_, err := WithBreaker(upstream, breaker).RoundTrip(req)
+				cancel()
+				var open *CircuitOpenError
+				if errors.As(err, &open) {
+					t.Fatalf("step %d (%s) was rejected by the open circuit", i, step)
+				}
+			}
+
+			if got := breaker.State(); got != tt.want {
+				t.Errorf("state = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package transport
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// Config configures the HTTP client of an upstream service
+type Config struct {
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	// Timeout limits the whole call including retries and reading the response body
+	Timeout             time.Duration
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+
+	Breaker BreakerConfig
+	Retry   RetryConfig
+
+	// Balancer spreads the attempts over the instances of the upstream, the
+	// request URL is used as is without it
+	Balancer *Balancer
+
+	// Middleware wraps every attempt of an upstream call, the first one is outermost
+	Middleware []Middleware
+}
+
+// Middleware wraps a round tripper with additional behaviour
+type Middleware func(http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc adapts a function to http.RoundTripper
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req)
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// NewClient creates the HTTP client for the upstream name. Requests pass the
+// retry policy first, every attempt is then sent to an instance chosen by the
+// balancer and goes through the middleware and the circuit breaker.
+func NewClient(name string, config Config) *http.Client {
+	var rt http.RoundTripper = newTransport(config)
+	rt = WithBreaker(rt, NewBreaker(name, config.Breaker))
+	for i := len(config.Middleware) - 1; i >= 0; i-- {
+		rt = config.Middleware[i](rt)
+	}
+	if config.Balancer != nil {
+		rt = config.Balancer.Middleware()(rt)
+	}
+	rt = WithRetry(rt, config.Retry)
+
+	return &http.Client{
+		Transport: rt,
+		Timeout:   config.Timeout,
+	}
+}
+
+// newTransport creates a pooled transport dedicated to one upstream
+func newTransport(config Config) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   config.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   config.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: config.ResponseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+		MaxIdleConns:          config.MaxIdleConnsPerHost,
+		MaxIdleConnsPerHost:   config.MaxIdleConnsPerHost,
+		IdleConnTimeout:       config.IdleConnTimeout,
+	}
+}
@@ -0,0 +1,112 @@
+package transport
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// RetryConfig configures retries of idempotent upstream requests
+type RetryConfig struct {
+	// Attempts is the maximum number of attempts including the first one
+	Attempts int
+	// BaseDelay is the backoff before the first retry, doubled on every retry
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff
+	MaxDelay time.Duration
+}
+
+// retryTransport retries idempotent requests that failed with a transport
+// error or a gateway error status
+type retryTransport struct {
+	next   http.RoundTripper
+	config RetryConfig
+}
+
+// WithRetry wraps next with bounded retries using exponential backoff with full jitter
+func WithRetry(next http.RoundTripper, config RetryConfig) http.RoundTripper {
+	if config.Attempts <= 1 {
+		return next
+	}
+	return &retryTransport{next: next, config: config}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !retryable(req) {
+		return t.next.RoundTrip(req)
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+		if attempt >= t.config.Attempts || req.Context().Err() != nil || !shouldRetry(resp, err) {
+			return resp, err
+		}
+
+		if resp != nil {
+			// Release the connection of the discarded response
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(t.backoff(attempt))
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		if req, err = rewind(req); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	delay := t.config.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > t.config.MaxDelay {
+		delay = t.config.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(delay)))
+}
+
+// retryable reports whether the request is idempotent and can be sent again
+func retryable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		var open *CircuitOpenError
+		return !errors.As(err, &open)
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// rewind returns a copy of the request with a fresh body
+func rewind(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	clone := req.Clone(req.Context())
+	clone.Body = body
+	return clone, nil
+}
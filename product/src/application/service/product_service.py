@@ -20,6 +20,9 @@ class ProductService:
 
         return products
 
+    def count_products(self) -> int:
+        return self.product_repo.count_products()
+
     def create_product(self,
                        product: ProductCreate
                        ) -> None | Product:
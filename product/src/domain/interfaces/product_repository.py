@@ -10,6 +10,10 @@ class AbstractProductRepository(ABC):
     def get_all_products(self, skip, limit) -> List[Product]:
         pass
 
+    @abstractmethod
+    def count_products(self) -> int:
+        pass
+
     @abstractmethod
     def get_product_by_id(self, product_id: int) -> Product:
         pass
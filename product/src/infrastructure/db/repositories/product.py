@@ -13,15 +13,25 @@ class ProductRepository(AbstractProductRepository):
         self.db = db
 
     def get_all_products(self, skip: int = 0, limit: int = 100) -> List[Product]:
+        """
+        Страница продуктов в порядке возрастания ID, порядок стабилен между запросами
+        """
         db_items = (
             self.db
             .query(ProductORM)
+            .order_by(ProductORM.id)
             .offset(skip)
             .limit(limit)
             .all()
         )
         return [self._to_domain(item) for item in db_items]
 
+    def count_products(self) -> int:
+        """
+        Число всех продуктов
+        """
+        return self.db.query(ProductORM).count()
+
     def get_product_by_id(self, product_id: int) -> Optional[Product]:
         """
         Найти продукт по ID
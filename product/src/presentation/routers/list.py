@@ -1,4 +1,4 @@
-from fastapi import APIRouter, Depends, Request
+from fastapi import APIRouter, Depends, Query, Response
 
 from src.presentation.routers.dependences import get_product_service
 from src.application.service.product_service import ProductService
@@ -6,8 +6,11 @@ from src.application.service.product_service import ProductService
 list_router = APIRouter()
 
 @list_router.get("/list")
-def read_products(skip: int = 0,
-                  limit: int = 100,
+def read_products(response: Response,
+                  skip: int = Query(0, ge=0),
+                  limit: int = Query(100, ge=1, le=500),
                   product_service: ProductService = Depends(get_product_service)):
-    products = product_service.read_products()
+    products = product_service.read_products(skip, limit)
+    # Общее число продуктов для постраничного вывода в gateway
+    response.headers["X-Total-Count"] = str(product_service.count_products())
     return products